@@ -3,8 +3,10 @@ package main
 import (
 	"encoding/xml"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,17 +18,64 @@ const (
 
 // List of XML RPC getter function codes.
 const (
-	FnCMSystemInfo = "2"
-	FnLANUserTable = "123"
-	FnCMState      = "136"
+	FnGlobalSettings  = "1"
+	FnCMSystemInfo    = "2"
+	FnDownstreamTable = "10"
+	FnUpstreamTable   = "11"
+	FnEventLogTable   = "13"
+	FnDHCPv6Info      = "103"
+	FnWANSetting      = "107"
+	FnIPFiltering     = "109"
+	FnMACFiltering    = "119"
+	FnForwarding      = "121"
+	FnLANUserTable    = "123"
+	FnCMState         = "136"
+	FnCMStatus        = "144"
+	FnWirelessBasic   = "300"
+	FnWirelessClient  = "322"
+)
+
+// List of XML RPC getter function codes, that are not confirmed to exist
+// on all firmware versions. Collectors using them are opt-in.
+const (
+	FnOFDMDownstreamTable = "17"
+	FnOFDMAUpstreamTable  = "18"
+	FnMTAStatus           = "145"
 )
 
 // List of string constants from the XML API responses.
 const (
-	OperStateOK          = "OPERATIONAL"
-	NetworkAccessAllowed = "Allowed"
+	OperStateOK           = "OPERATIONAL"
+	LockedOutEnabled      = "Enable"
+	NetworkAccessAllowed  = "Allowed"
+	CMProvisioningOnline  = "Online"
+	CMBPIEnabled          = "1"
+	ProtocolTCP           = "1"
+	ProtocolUDP           = "2"
+	ProtocolBoth          = "3"
+	IPv6ManagedFlagOn     = "1"
+	TimeModeAlways        = "0"
+	TimeModeGeneral       = "1"
+	TimeModeDaily         = "2"
+	LANClientMethodDHCP   = "1"
+	LANClientMethodStatic = "2"
+	WirelessEnabled       = "1"
+	WirelessHidden        = "1"
+	WirelessChannelAuto   = "0"
+	MTAProvisioningPass   = "Pass"
+	MTALineRegistered     = "Registered"
+	MTALineOffHook        = "Off-Hook"
 )
 
+// GlobalSettings shows router firmware and access settings.
+type GlobalSettings struct {
+	SwVersion   string `xml:"SwVersion"`
+	Model       string `xml:"ConfigVenderModel"`
+	OperatorID  string `xml:"OperatorId"`
+	AccessLevel string `xml:"AccessLevel"`
+	LockedOut   string `xml:"LockedOut"`
+}
+
 // CMSystemInfo shows cable modem system info.
 type CMSystemInfo struct {
 	DocsisMode      string `xml:"cm_docsis_mode"`
@@ -60,6 +109,269 @@ func (c *CMSystemInfo) UnmarshalXML(d *xml.Decoder, start xml.StartElement) erro
 	return nil
 }
 
+// DownstreamTable is a list of DOCSIS downstream channels.
+type DownstreamTable struct {
+	Channels []DownstreamTableChannel `xml:"downstream"`
+}
+
+// DownstreamTableChannel is a single downstream channel.
+type DownstreamTableChannel struct {
+	ChannelID    string  `xml:"chid"`
+	Frequency    int     `xml:"freq"`
+	Power        float64 `xml:"pow"`
+	SNR          float64 `xml:"snr"`
+	RxMER        float64 `xml:"RxMER"`
+	Modulation   string  `xml:"mod"`
+	PreRS        int     `xml:"PreRs"`  // codewords corrected by Reed-Solomon
+	PostRS       int     `xml:"PostRs"` // uncorrectable codewords
+	IsQAMLocked  bool    `xml:"IsQamLocked"`
+	IsFECLocked  bool    `xml:"IsFECLocked"`
+	IsMPEGLocked bool    `xml:"IsMpegLocked"`
+}
+
+// UpstreamTable is a list of DOCSIS upstream channels.
+type UpstreamTable struct {
+	Channels []UpstreamTableChannel `xml:"upstream"`
+}
+
+// UpstreamTableChannel is a single upstream channel.
+type UpstreamTableChannel struct {
+	ChannelID   string  `xml:"usid"`
+	Frequency   int     `xml:"freq"`
+	Power       float64 `xml:"power"`
+	SymbolRate  int     `xml:"srate"`
+	Modulation  string  `xml:"mod"`
+	ChannelType string  `xml:"channeltype"`
+	T1Timeouts  int     `xml:"t1Timeouts"`
+	T2Timeouts  int     `xml:"t2Timeouts"`
+	T3Timeouts  int     `xml:"t3Timeouts"`
+	T4Timeouts  int     `xml:"t4Timeouts"`
+}
+
+// UnmarshalXML is a standard unmarshaller + Msym/s to sym/s convertor.
+func (c *UpstreamTableChannel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Alias UpstreamTableChannel
+	aux := &struct {
+		*Alias
+		SymbolRate string `xml:"srate"`
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	// Inactive channels are reported without symbol rate
+	if aux.SymbolRate == "" {
+		return nil
+	}
+	rate, err := strconv.ParseFloat(aux.SymbolRate, 64)
+	if err != nil {
+		return fmt.Errorf("invalid symbol rate: %w", err)
+	}
+	c.SymbolRate = int(math.Round(rate * 1e6))
+
+	return nil
+}
+
+// OFDMDownstreamTable is a list of DOCSIS 3.1 OFDM downstream channels.
+type OFDMDownstreamTable struct {
+	Channels []OFDMDownstreamTableChannel `xml:"ofdm_downstream"`
+}
+
+// OFDMDownstreamTableChannel is a single OFDM downstream channel.
+type OFDMDownstreamTableChannel struct {
+	ChannelID             string   `xml:"chid"`
+	StartFrequency        int      `xml:"start_frequency"`
+	EndFrequency          int      `xml:"end_frequency"`
+	FirstActiveSubcarrier int      `xml:"first_active_subcarrier"`
+	LastActiveSubcarrier  int      `xml:"last_active_subcarrier"`
+	ActiveSubcarriers     int      `xml:"num_active_subcarriers"`
+	FFTSize               int      `xml:"FFT_type"`
+	PLCPower              float64  `xml:"plc_power"`
+	RxMER                 float64  `xml:"RxMER"`
+	ProfileIDs            []string `xml:"profile_id"`
+	IsLocked              bool     `xml:"locked"`
+}
+
+// UnmarshalXML is a standard unmarshaller + FFT size and profiles list parser.
+func (c *OFDMDownstreamTableChannel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Alias OFDMDownstreamTableChannel
+	aux := &struct {
+		*Alias
+		FFTSize    string `xml:"FFT_type"`
+		ProfileIDs string `xml:"profile_id"`
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	size, err := parseFFTSize(aux.FFTSize)
+	if err != nil {
+		return err
+	}
+	c.FFTSize = size
+	c.ProfileIDs = parseList(aux.ProfileIDs)
+
+	return nil
+}
+
+// OFDMAUpstreamTable is a list of DOCSIS 3.1 OFDMA upstream channels.
+type OFDMAUpstreamTable struct {
+	Channels []OFDMAUpstreamTableChannel `xml:"ofdma_upstream"`
+}
+
+// OFDMAUpstreamTableChannel is a single OFDMA upstream channel.
+type OFDMAUpstreamTableChannel struct {
+	ChannelID             string   `xml:"usid"`
+	StartFrequency        int      `xml:"start_frequency"`
+	EndFrequency          int      `xml:"end_frequency"`
+	FirstActiveSubcarrier int      `xml:"first_active_subcarrier"`
+	LastActiveSubcarrier  int      `xml:"last_active_subcarrier"`
+	ActiveSubcarriers     int      `xml:"num_active_subcarriers"`
+	FFTSize               int      `xml:"FFT_type"`
+	Power                 float64  `xml:"power"`
+	ProfileIDs            []string `xml:"profile_id"`
+	IsRanged              bool     `xml:"ranged"`
+}
+
+// UnmarshalXML is a standard unmarshaller + FFT size and profiles list parser.
+func (c *OFDMAUpstreamTableChannel) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Alias OFDMAUpstreamTableChannel
+	aux := &struct {
+		*Alias
+		FFTSize    string `xml:"FFT_type"`
+		ProfileIDs string `xml:"profile_id"`
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	size, err := parseFFTSize(aux.FFTSize)
+	if err != nil {
+		return err
+	}
+	c.FFTSize = size
+	c.ProfileIDs = parseList(aux.ProfileIDs)
+
+	return nil
+}
+
+// EventLogTable is a list of DOCSIS events logged by the cable modem.
+type EventLogTable struct {
+	Events []EventLogTableEvent `xml:"eventlog"`
+}
+
+// EventLogTableEvent is a single event log entry.
+type EventLogTableEvent struct {
+	Priority  string `xml:"prior"`
+	Text      string `xml:"text"`
+	Time      string `xml:"time"`
+	Timestamp int64  `xml:"t"`
+}
+
+// ID returns event message without the trailing parameters, that are
+// different for every entry. Input format:
+// "No Ranging Response received - T3 time-out;CM-MAC=00:00:00:00:00:00;".
+func (e EventLogTableEvent) ID() string {
+	id, _, _ := strings.Cut(e.Text, ";")
+	return strings.TrimSpace(id)
+}
+
+// DHCPv6Info shows LAN IPv6 settings and the delegated prefix.
+type DHCPv6Info struct {
+	ManagedFlag       string `xml:"ipv6RAManagedflag"`
+	Prefix            string `xml:"ipv6_prefix"`
+	PreferredLifetime int    `xml:"ipv6PrefixPreferredLifeTime"`
+	ValidLifetime     int    `xml:"ipv6PrefixValidLifeTime"`
+}
+
+// WANSetting shows WAN settings received from the provider.
+type WANSetting struct {
+	IPv4DNSAddrs []string `xml:"wan_ipv4_dnsaddr>wan_ipv4_dnsaddr_entry"`
+	IPv6DNSAddrs []string `xml:"wan_ipv6_dnsaddr>wan_ipv6_dnsaddr_entry"`
+}
+
+// IPFiltering is a list of firewall IP filter rules.
+type IPFiltering struct {
+	Rules []IPFilteringRule `xml:"Instance"`
+}
+
+// IPFilteringRule is a single rule that blocks traffic between LAN
+// address range and WAN address range.
+type IPFilteringRule struct {
+	ID           string `xml:"idd"`
+	SrcAddrStart string `xml:"src_addr_s"`
+	SrcAddrEnd   string `xml:"src_addr_e"`
+	DstAddrStart string `xml:"dst_addr_s"`
+	DstAddrEnd   string `xml:"dst_addr_e"`
+	Protocol     string `xml:"protocol"`
+	DstPortStart int    `xml:"dst_port_s"`
+	DstPortEnd   int    `xml:"dst_port_e"`
+	Enabled      bool   `xml:"enable"`
+}
+
+// MACFiltering is a list of devices with restricted internet access
+// (parental control), and the schedule when the restrictions apply.
+type MACFiltering struct {
+	TimeMode    string             `xml:"time_mode"`
+	GeneralTime []string           `xml:"GeneralTime"`
+	DailyTime   []string           `xml:"DailyTime"`
+	Rules       []MACFilteringRule `xml:"Instance"`
+}
+
+// UnmarshalXML is a standard unmarshaller + schedule windows list parser.
+func (c *MACFiltering) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Alias MACFiltering
+	aux := &struct {
+		*Alias
+		GeneralTime string `xml:"GeneralTime"`
+		DailyTime   string `xml:"DailyTime"`
+	}{
+		Alias: (*Alias)(c),
+	}
+
+	if err := d.DecodeElement(&aux, &start); err != nil {
+		return err //nolint:wrapcheck
+	}
+	c.GeneralTime = parseList(aux.GeneralTime)
+	c.DailyTime = parseList(aux.DailyTime)
+
+	return nil
+}
+
+// MACFilteringRule is a single device with restricted access.
+type MACFilteringRule struct {
+	ID         string `xml:"idd"`
+	MACAddr    string `xml:"MACAddr"`
+	DeviceName string `xml:"DeviceName"`
+	Enabled    bool   `xml:"enable"`
+}
+
+// Forwarding is a list of port forwarding rules.
+type Forwarding struct {
+	Rules []ForwardingRule `xml:"Instance"`
+}
+
+// ForwardingRule is a single port forwarding rule.
+type ForwardingRule struct {
+	ID                string `xml:"idd"`
+	LocalIP           string `xml:"local_IP"`
+	ExternalPortStart int    `xml:"start_port"`
+	ExternalPortEnd   int    `xml:"end_port"`
+	InternalPortStart int    `xml:"start_portIn"`
+	InternalPortEnd   int    `xml:"end_portIn"`
+	Protocol          string `xml:"protocol"`
+	Enabled           bool   `xml:"enable"`
+}
+
 // LANUserTable is a list of connected devices.
 type LANUserTable struct {
 	Ethernet []LANUserTableClientInfo `xml:"Ethernet>clientinfo"`
@@ -106,6 +418,66 @@ func (c *CMState) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+// CMStatus shows cable modem provisioning status. The provisioning step
+// number tells how far the modem got, the comment describes the step.
+type CMStatus struct {
+	ProvisioningState string `xml:"provisioning_st"`
+	ProvisioningStep  string `xml:"provisioning_st_num"`
+	Comment           string `xml:"cm_comment"`
+	ConfigFile        string `xml:"FileName"`
+	BPIEnabled        string `xml:"bpiEnable"`
+}
+
+// MTAStatus shows telephony adapter (eMTA) provisioning and lines status.
+type MTAStatus struct {
+	ProvisioningState string          `xml:"provisioning_st"`
+	Lines             []MTAStatusLine `xml:"line"`
+}
+
+// MTAStatusLine is a single telephone line.
+type MTAStatusLine struct {
+	LineID    string `xml:"line_id"`
+	Enabled   bool   `xml:"line_enabled"`
+	Status    string `xml:"line_status"`
+	HookState string `xml:"hook_state"`
+}
+
+// WirelessBasic shows Wi-Fi radio settings for both bands.
+type WirelessBasic struct {
+	BSSEnable2G        string `xml:"BssEnable2g"`
+	SSID2G             string `xml:"SSID2G"`
+	HideNetwork2G      string `xml:"HideNetwork2G"`
+	BandWidth2G        string `xml:"BandWidth2G"`
+	TransmissionMode2G string `xml:"TransmissionMode2g"`
+	SecurityMode2G     string `xml:"SecurityMode2g"`
+	ChannelSetting2G   string `xml:"ChannelSetting2G"`
+	CurrentChannel2G   int    `xml:"CurrentChannel2G"`
+	BSSEnable5G        string `xml:"BssEnable5g"`
+	SSID5G             string `xml:"SSID5G"`
+	HideNetwork5G      string `xml:"HideNetwork5G"`
+	BandWidth5G        string `xml:"BandWidth5G"`
+	TransmissionMode5G string `xml:"TransmissionMode5g"`
+	SecurityMode5G     string `xml:"SecurityMode5g"`
+	ChannelSetting5G   string `xml:"ChannelSetting5G"`
+	CurrentChannel5G   int    `xml:"CurrentChannel5G"`
+}
+
+// WirelessClient is a list of devices connected over Wi-Fi.
+type WirelessClient struct {
+	Clients2G []WirelessClientInfo `xml:"Client2G>clientinfo"`
+	Clients5G []WirelessClientInfo `xml:"Client5G>clientinfo"`
+}
+
+// WirelessClientInfo is a device connected over Wi-Fi.
+type WirelessClientInfo struct {
+	SSID      string `xml:"SSID"`
+	MACAddr   string `xml:"MAC"`
+	PhyRateTx int    `xml:"phy_rate_tx"`
+	PhyRateRx int    `xml:"phy_rate_rx"`
+	PhyMode   string `xml:"phy_mode"`
+	RSSI      int    `xml:"RSSI"`
+}
+
 var durationRegexp = regexp.MustCompile(`(?:(\d+)day\(s\))?(\d+)h:(\d+)m:(\d+)s`)
 
 // Input format: "1day(s)2h:34m:56s".
@@ -128,6 +500,58 @@ func parseDuration(s string) (time.Duration, error) {
 	return dur, nil
 }
 
+var leaseTimeRegexp = regexp.MustCompile(`^(\d+):(\d+):(\d+):(\d+)$`)
+
+// Input format: "01:02:34:56" (days:hours:minutes:seconds).
+func parseLeaseTime(s string) (time.Duration, error) {
+	matches := leaseTimeRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if len(matches) != 5 {
+		return 0, fmt.Errorf("invalid lease time string")
+	}
+
+	days, _ := strconv.Atoi(matches[1])
+	hours, _ := strconv.Atoi(matches[2])
+	minutes, _ := strconv.Atoi(matches[3])
+	seconds, _ := strconv.Atoi(matches[4])
+
+	dur := time.Duration(days)*24*time.Hour +
+		time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second
+
+	return dur, nil
+}
+
+// Input format: "4K", "8K" or a plain number of subcarriers. Empty value
+// is reported for inactive channels.
+func parseFFTSize(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	mul := 1
+	if strings.HasSuffix(s, "K") {
+		s = strings.TrimSuffix(s, "K")
+		mul = 1024
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid fft size: %w", err)
+	}
+	return n * mul, nil
+}
+
+// Input format: "0,1,2".
+func parseList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func fahrenheitToCelsius(f int) int {
 	return (f - 32) * 5.0 / 9
 }
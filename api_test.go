@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/xml"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -99,6 +100,432 @@ func TestCMState_UnmarshalXML(t *testing.T) {
 	})
 }
 
+func TestDownstreamTable_UnmarshalXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<downstream_table>` +
+		`<ds_num>1</ds_num>` +
+		`<downstream>` +
+		`<freq>762000000</freq>` +
+		`<pow>5.5</pow>` +
+		`<snr>40</snr>` +
+		`<mod>256qam</mod>` +
+		`<chid>1</chid>` +
+		`<RxMER>38.983</RxMER>` +
+		`<PreRs>100</PreRs>` +
+		`<PostRs>10</PostRs>` +
+		`<IsQamLocked>1</IsQamLocked>` +
+		`<IsFECLocked>1</IsFECLocked>` +
+		`<IsMpegLocked>0</IsMpegLocked>` +
+		`</downstream>` +
+		`</downstream_table>`
+
+	var table DownstreamTable
+	err := xml.Unmarshal([]byte(data), &table)
+	require.NoError(t, err)
+
+	expected := DownstreamTable{
+		Channels: []DownstreamTableChannel{{
+			ChannelID:    "1",
+			Frequency:    762000000,
+			Power:        5.5,
+			SNR:          40,
+			RxMER:        38.983,
+			Modulation:   "256qam",
+			PreRS:        100,
+			PostRS:       10,
+			IsQAMLocked:  true,
+			IsFECLocked:  true,
+			IsMPEGLocked: false,
+		}},
+	}
+	require.Equal(t, expected, table)
+}
+
+func TestUpstreamTableChannel_UnmarshalXML(t *testing.T) {
+	t.Run("valid xml", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<upstream_table>` +
+			`<us_num>1</us_num>` +
+			`<upstream>` +
+			`<usid>1</usid>` +
+			`<freq>30800000</freq>` +
+			`<power>44.5</power>` +
+			`<srate>5.120</srate>` +
+			`<mod>64qam</mod>` +
+			`<ustype>3</ustype>` +
+			`<t1Timeouts>1</t1Timeouts>` +
+			`<t2Timeouts>2</t2Timeouts>` +
+			`<t3Timeouts>3</t3Timeouts>` +
+			`<t4Timeouts>4</t4Timeouts>` +
+			`<channeltype>ATDMA</channeltype>` +
+			`<messageType>29</messageType>` +
+			`</upstream>` +
+			`</upstream_table>`
+
+		var table UpstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.NoError(t, err)
+
+		expected := UpstreamTable{
+			Channels: []UpstreamTableChannel{{
+				ChannelID:   "1",
+				Frequency:   30800000,
+				Power:       44.5,
+				SymbolRate:  5120000,
+				Modulation:  "64qam",
+				ChannelType: "ATDMA",
+				T1Timeouts:  1,
+				T2Timeouts:  2,
+				T3Timeouts:  3,
+				T4Timeouts:  4,
+			}},
+		}
+		require.Equal(t, expected, table)
+	})
+
+	t.Run("empty symbol rate", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<upstream_table>` +
+			`<upstream>` +
+			`<usid>1</usid>` +
+			`<srate></srate>` +
+			`</upstream>` +
+			`</upstream_table>`
+
+		var table UpstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.NoError(t, err)
+
+		expected := UpstreamTable{
+			Channels: []UpstreamTableChannel{{ChannelID: "1"}},
+		}
+		require.Equal(t, expected, table)
+	})
+
+	t.Run("invalid symbol rate", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<upstream_table>` +
+			`<upstream>` +
+			`<usid>1</usid>` +
+			`<srate>hello, world</srate>` +
+			`</upstream>` +
+			`</upstream_table>`
+
+		var table UpstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.ErrorContains(t, err, "invalid symbol rate")
+	})
+
+	t.Run("invalid xml", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?><upstream_table><upstream>`
+
+		var table UpstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.ErrorContains(t, err, "XML syntax error")
+	})
+}
+
+func TestOFDMDownstreamTableChannel_UnmarshalXML(t *testing.T) {
+	t.Run("valid xml", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<ofdm_downstream_table>` +
+			`<ofdm_downstream>` +
+			`<chid>33</chid>` +
+			`<start_frequency>135000000</start_frequency>` +
+			`<end_frequency>327000000</end_frequency>` +
+			`<first_active_subcarrier>148</first_active_subcarrier>` +
+			`<last_active_subcarrier>3947</last_active_subcarrier>` +
+			`<num_active_subcarriers>3800</num_active_subcarriers>` +
+			`<FFT_type>4K</FFT_type>` +
+			`<plc_power>3.1</plc_power>` +
+			`<RxMER>41.5</RxMER>` +
+			`<profile_id>0,1,2</profile_id>` +
+			`<locked>1</locked>` +
+			`</ofdm_downstream>` +
+			`</ofdm_downstream_table>`
+
+		var table OFDMDownstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.NoError(t, err)
+
+		expected := OFDMDownstreamTable{
+			Channels: []OFDMDownstreamTableChannel{{
+				ChannelID:             "33",
+				StartFrequency:        135000000,
+				EndFrequency:          327000000,
+				FirstActiveSubcarrier: 148,
+				LastActiveSubcarrier:  3947,
+				ActiveSubcarriers:     3800,
+				FFTSize:               4096,
+				PLCPower:              3.1,
+				RxMER:                 41.5,
+				ProfileIDs:            []string{"0", "1", "2"},
+				IsLocked:              true,
+			}},
+		}
+		require.Equal(t, expected, table)
+	})
+
+	t.Run("empty fft size", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<ofdm_downstream_table>` +
+			`<ofdm_downstream>` +
+			`<chid>33</chid>` +
+			`<FFT_type></FFT_type>` +
+			`</ofdm_downstream>` +
+			`</ofdm_downstream_table>`
+
+		var table OFDMDownstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.NoError(t, err)
+
+		expected := OFDMDownstreamTable{
+			Channels: []OFDMDownstreamTableChannel{{ChannelID: "33"}},
+		}
+		require.Equal(t, expected, table)
+	})
+
+	t.Run("invalid fft size", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<ofdm_downstream_table>` +
+			`<ofdm_downstream>` +
+			`<FFT_type>hello, world</FFT_type>` +
+			`</ofdm_downstream>` +
+			`</ofdm_downstream_table>`
+
+		var table OFDMDownstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.ErrorContains(t, err, "invalid fft size")
+	})
+}
+
+func TestOFDMAUpstreamTableChannel_UnmarshalXML(t *testing.T) {
+	t.Run("valid xml", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<ofdma_upstream_table>` +
+			`<ofdma_upstream>` +
+			`<usid>9</usid>` +
+			`<start_frequency>29800000</start_frequency>` +
+			`<end_frequency>64800000</end_frequency>` +
+			`<first_active_subcarrier>74</first_active_subcarrier>` +
+			`<last_active_subcarrier>1275</last_active_subcarrier>` +
+			`<num_active_subcarriers>1200</num_active_subcarriers>` +
+			`<FFT_type>2048</FFT_type>` +
+			`<power>42</power>` +
+			`<profile_id>5</profile_id>` +
+			`<ranged>1</ranged>` +
+			`</ofdma_upstream>` +
+			`</ofdma_upstream_table>`
+
+		var table OFDMAUpstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.NoError(t, err)
+
+		expected := OFDMAUpstreamTable{
+			Channels: []OFDMAUpstreamTableChannel{{
+				ChannelID:             "9",
+				StartFrequency:        29800000,
+				EndFrequency:          64800000,
+				FirstActiveSubcarrier: 74,
+				LastActiveSubcarrier:  1275,
+				ActiveSubcarriers:     1200,
+				FFTSize:               2048,
+				Power:                 42,
+				ProfileIDs:            []string{"5"},
+				IsRanged:              true,
+			}},
+		}
+		require.Equal(t, expected, table)
+	})
+
+	t.Run("invalid xml", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?><ofdma_upstream_table><ofdma_upstream>`
+
+		var table OFDMAUpstreamTable
+		err := xml.Unmarshal([]byte(data), &table)
+		require.ErrorContains(t, err, "XML syntax error")
+	})
+}
+
+func TestEventLogTableEvent_ID(t *testing.T) {
+	testCases := []struct {
+		name string
+		text string
+		id   string
+	}{
+		{
+			name: "with parameters",
+			text: "No Ranging Response received - T3 time-out;CM-MAC=00:00:00:00:00:00;",
+			id:   "No Ranging Response received - T3 time-out",
+		},
+		{
+			name: "without parameters",
+			text: "Cable Modem Reboot due to power reset",
+			id:   "Cable Modem Reboot due to power reset",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			e := EventLogTableEvent{Text: tc.text}
+			require.Equal(t, tc.id, e.ID())
+		})
+	}
+}
+
+func TestCMStatus_UnmarshalXML(t *testing.T) {
+	// Real response, lists of channels and service flows are cut
+	data := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<cmstatus>` +
+		`<provisioning_st>Online</provisioning_st>` +
+		`<provisioning_st_num>12</provisioning_st_num>` +
+		`<cm_comment>Operational</cm_comment>` +
+		`<ds_num>32</ds_num>` +
+		`<downstream>` +
+		`<freq>682000000</freq>` +
+		`<mod>256qam</mod>` +
+		`<chid>14</chid>` +
+		`<state>4</state>` +
+		`<status>0</status>` +
+		`<primarySettings>0</primarySettings>` +
+		`</downstream>` +
+		`<us_num>4</us_num>` +
+		`<upstream>` +
+		`<usid>8</usid>` +
+		`<freq>52000000</freq>` +
+		`<power>101</power>` +
+		`<srate>5.120</srate>` +
+		`<state>4</state>` +
+		`</upstream>` +
+		`<cm_docsis_mode>DOCSIS 3.0</cm_docsis_mode>` +
+		`<cm_network_access>Allowed</cm_network_access>` +
+		`<NumberOfCpes>45</NumberOfCpes>` +
+		`<dMaxCpes>2</dMaxCpes>` +
+		`<bpiEnable>1</bpiEnable>` +
+		`<FileName>bac1020001066800000001c8</FileName>` +
+		`<serviceflow>` +
+		`<Sfid>200000001</Sfid>` +
+		`<direction>2</direction>` +
+		`<pMaxTrafficRate>32100000</pMaxTrafficRate>` +
+		`<pMaxTrafficBurst>42600</pMaxTrafficBurst>` +
+		`<pMinReservedRate>0</pMinReservedRate>` +
+		`<pMaxConcatBurst>42600</pMaxConcatBurst>` +
+		`<pSchedulingType>2</pSchedulingType>` +
+		`</serviceflow>` +
+		`</cmstatus>`
+
+	var status CMStatus
+	err := xml.Unmarshal([]byte(data), &status)
+	require.NoError(t, err)
+
+	expected := CMStatus{
+		ProvisioningState: "Online",
+		ProvisioningStep:  "12",
+		Comment:           "Operational",
+		ConfigFile:        "bac1020001066800000001c8",
+		BPIEnabled:        CMBPIEnabled,
+	}
+	require.Equal(t, expected, status)
+}
+
+func TestWirelessClient_UnmarshalXML(t *testing.T) {
+	t.Run("valid xml", func(t *testing.T) {
+		data := `<?xml version="1.0" encoding="utf-8"?>` +
+			`<WirelessClient>` +
+			`<Client2G>` +
+			`<clientinfo>` +
+			`<SSID>SSID2G</SSID>` +
+			`<MAC>00:00:00:00:00:01</MAC>` +
+			`<phy_rate_tx>72</phy_rate_tx>` +
+			`<phy_rate_rx>65</phy_rate_rx>` +
+			`<phy_mode>11n</phy_mode>` +
+			`<RSSI>-71</RSSI>` +
+			`</clientinfo>` +
+			`</Client2G>` +
+			`<Client5G>` +
+			`<clientinfo>` +
+			`<SSID>SSID5G</SSID>` +
+			`<MAC>00:00:00:00:00:02</MAC>` +
+			`<phy_rate_tx>866</phy_rate_tx>` +
+			`<phy_rate_rx>780</phy_rate_rx>` +
+			`<phy_mode>11ac</phy_mode>` +
+			`<RSSI>-48</RSSI>` +
+			`</clientinfo>` +
+			`</Client5G>` +
+			`</WirelessClient>`
+
+		var wc WirelessClient
+		err := xml.Unmarshal([]byte(data), &wc)
+		require.NoError(t, err)
+
+		expected := WirelessClient{
+			Clients2G: []WirelessClientInfo{{
+				SSID:      "SSID2G",
+				MACAddr:   "00:00:00:00:00:01",
+				PhyRateTx: 72,
+				PhyRateRx: 65,
+				PhyMode:   "11n",
+				RSSI:      -71,
+			}},
+			Clients5G: []WirelessClientInfo{{
+				SSID:      "SSID5G",
+				MACAddr:   "00:00:00:00:00:02",
+				PhyRateTx: 866,
+				PhyRateRx: 780,
+				PhyMode:   "11ac",
+				RSSI:      -48,
+			}},
+		}
+		require.Equal(t, expected, wc)
+	})
+}
+
+func TestParseLeaseTime(t *testing.T) {
+	t.Run("valid string", func(t *testing.T) {
+		dur, err := parseLeaseTime("01:02:03:04")
+		require.NoError(t, err)
+		require.Equal(t, 26*time.Hour+3*time.Minute+4*time.Second, dur)
+	})
+
+	t.Run("invalid string", func(t *testing.T) {
+		_, err := parseLeaseTime("hello, world")
+		require.ErrorContains(t, err, "invalid lease time string")
+	})
+}
+
+func TestMACFiltering_UnmarshalXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<MACFiltering>` +
+		`<maxInstance>16</maxInstance>` +
+		`<time_mode>1</time_mode>` +
+		`<GeneralTime>08:00-12:00,13:00-20:00</GeneralTime>` +
+		`<DailyTime></DailyTime>` +
+		`<Instance>` +
+		`<idd>1</idd>` +
+		`<MACAddr>00:00:00:00:00:01</MACAddr>` +
+		`<DeviceName>tablet</DeviceName>` +
+		`<enable>1</enable>` +
+		`</Instance>` +
+		`</MACFiltering>`
+
+	var mf MACFiltering
+	err := xml.Unmarshal([]byte(data), &mf)
+	require.NoError(t, err)
+
+	expected := MACFiltering{
+		TimeMode:    TimeModeGeneral,
+		GeneralTime: []string{"08:00-12:00", "13:00-20:00"},
+		Rules: []MACFilteringRule{{
+			ID:         "1",
+			MACAddr:    "00:00:00:00:00:01",
+			DeviceName: "tablet",
+			Enabled:    true,
+		}},
+	}
+	require.Equal(t, expected, mf)
+}
+
 func TestFahrenheitToCelsius(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var loginBackoffGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "connect_box_exporter_login_backoff_until_timestamp_seconds",
+	Help: "Time until login attempts are suppressed after failures, 0 if not suppressed.",
+}, []string{"target"})
+
+// backoffError is returned when a login attempt is suppressed.
+type backoffError struct {
+	until time.Time
+}
+
+func (e *backoffError) Error() string {
+	return fmt.Sprintf("login suppressed until %s", e.until.Format(time.RFC3339))
+}
+
+// backoffClient is a ConnectBox client, that suppresses login attempts
+// after failures, so the router doesn't extend its login lockout. Backoff
+// doubles after each failure up to the max value, and is reset after
+// a successful login.
+type backoffClient struct {
+	target   string
+	client   ConnectBox
+	base     time.Duration
+	maxDelay time.Duration
+
+	mu       sync.Mutex
+	failures int
+	until    time.Time
+}
+
+func newBackoffClient(target string, client ConnectBox, base, maxDelay time.Duration) *backoffClient {
+	return &backoffClient{target: target, client: client, base: base, maxDelay: maxDelay}
+}
+
+// Login logs in to the router, unless login attempts are suppressed.
+func (c *backoffClient) Login(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(c.until) {
+		return &backoffError{until: c.until}
+	}
+
+	if err := c.client.Login(ctx); err != nil {
+		c.failures++
+		c.until = now.Add(c.delay())
+		loginBackoffGauge.WithLabelValues(c.target).Set(float64(c.until.Unix()))
+		return err //nolint:wrapcheck
+	}
+
+	c.failures = 0
+	c.until = time.Time{}
+	loginBackoffGauge.WithLabelValues(c.target).Set(0)
+	return nil
+}
+
+// Logout logs out from the router.
+func (c *backoffClient) Logout(ctx context.Context) error {
+	return c.client.Logout(ctx) //nolint:wrapcheck
+}
+
+// Get gets data from the router.
+func (c *backoffClient) Get(ctx context.Context, fn string, out any) error {
+	return c.client.Get(ctx, fn, out) //nolint:wrapcheck
+}
+
+// delay returns backoff duration for the current number of failures.
+func (c *backoffClient) delay() time.Duration {
+	d := c.base
+	for i := 1; i < c.failures && d < c.maxDelay; i++ {
+		d *= 2
+	}
+	if d > c.maxDelay {
+		d = c.maxDelay
+	}
+	return d
+}
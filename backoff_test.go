@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestBackoffClient_Login(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	client := NewMockConnectBox(ctrl)
+	gomock.InOrder(
+		client.EXPECT().Login(gomock.Any()).Return(errors.New("fail")),
+		client.EXPECT().Login(gomock.Any()).Return(errors.New("fail")),
+		client.EXPECT().Login(gomock.Any()).Return(errors.New("fail")),
+		client.EXPECT().Login(gomock.Any()).Return(nil),
+	)
+
+	c := newBackoffClient("127.0.0.1", client, time.Minute, 3*time.Minute)
+	ctx := context.Background()
+
+	// First failure
+	require.EqualError(t, c.Login(ctx), "fail")
+	require.Equal(t, time.Minute, time.Until(c.until).Round(time.Minute))
+
+	// Suppressed attempt
+	var backoffErr *backoffError
+	require.ErrorAs(t, c.Login(ctx), &backoffErr)
+	require.Equal(t, c.until, backoffErr.until)
+
+	// Second failure doubles the backoff
+	c.until = time.Now()
+	require.EqualError(t, c.Login(ctx), "fail")
+	require.Equal(t, 2*time.Minute, time.Until(c.until).Round(time.Minute))
+
+	// Third failure hits the max value
+	c.until = time.Now()
+	require.EqualError(t, c.Login(ctx), "fail")
+	require.Equal(t, 3*time.Minute, time.Until(c.until).Round(time.Minute))
+
+	// Success resets the backoff
+	c.until = time.Now()
+	require.NoError(t, c.Login(ctx))
+	require.Equal(t, 0, c.failures)
+	require.True(t, c.until.IsZero())
+}
+
+func TestCollector_ServeHTTP_backoff(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	ctrl := gomock.NewController(t)
+
+	client := NewMockConnectBox(ctrl)
+	client.EXPECT().Login(gomock.Any()).Return(errors.New("fail"))
+
+	col := &Collector{
+		targets: map[string]ConnectBox{
+			"127.0.0.1": newBackoffClient("127.0.0.1", client, time.Minute, time.Hour),
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/probe?target=127.0.0.1", nil)
+	require.NoError(t, err)
+
+	// The first attempt fails
+	rec := httptest.NewRecorder()
+	col.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "\nconnect_box_scrape_success 0\n")
+
+	// The second attempt is suppressed
+	rec = httptest.NewRecorder()
+	col.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+}
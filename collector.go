@@ -2,212 +2,1443 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
+// descs is a list of all metric descriptors exposed by the collector.
+var descs []*prometheus.Desc
+
 // Collector collects metrics from a remote ConnectBox router.
 type Collector struct {
-	timeout time.Duration
-	targets map[string]ConnectBox
+	timeout       time.Duration
+	timeoutOffset time.Duration
+	logoutTimeout time.Duration
+	queueTimeout  time.Duration
+	coalesce      bool
+	targets       map[string]ConnectBox
+	// modules are sets of enabled collectors by module name
+	modules map[string]map[string]bool
+	// targetConfs are target settings by address
+	targetConfs map[string]Target
+
+	// Settings of client decorators
+	loginBackoff    time.Duration
+	loginBackoffMax time.Duration
+	sessionMaxAge   time.Duration
+	retry           RetryPolicy
+
+	// Settings of dynamic targets
+	authProfiles map[string]AuthProfile
+	allowedCIDRs []netip.Prefix
+	newClient    func(addr, username, password string) (ConnectBox, error)
+	maxDynamic   int
+
+	mu      sync.Mutex
+	events  map[string]*eventLog
+	pollers map[string]*poller
+	queues  map[string]*queue
+	dynamic map[dynamicTarget]*dynamicClient
 }
 
-// NewCollector creates new collector.
-func NewCollector(timeout time.Duration, targets map[string]ConnectBox) *Collector {
-	return &Collector{timeout: timeout, targets: targets}
+// NewCollector creates new collector. All target clients are instrumented
+// to record exporter metrics, retry failed requests according to the retry
+// policy, suppress logins after failures if login backoff is enabled, and
+// are wrapped into sessions if session reuse is enabled. The same applies
+// to clients of dynamic targets.
+func NewCollector(conf Config, targets map[string]ConnectBox) *Collector {
+	c := &Collector{
+		timeout:         conf.Timeout,
+		logoutTimeout:   conf.LogoutTimeout,
+		queueTimeout:    conf.QueueTimeout,
+		coalesce:        conf.Coalesce,
+		loginBackoffMax: conf.LoginBackoffMax,
+		sessionMaxAge:   conf.SessionMaxAge,
+		newClient:       newClient,
+		maxDynamic:      conf.MaxDynamicTargets,
+	}
+	if conf.TimeoutOffset != nil {
+		c.timeoutOffset = *conf.TimeoutOffset
+	}
+	if conf.LoginBackoff != nil {
+		c.loginBackoff = *conf.LoginBackoff
+	}
+	c.load(conf, targets)
+	return c
 }
 
-// ServeHTTP handles requests from Prometheus. It collects all metrics,
-// writes them to a temporary registry, and then returns.
+// wrap decorates the target client according to the collector settings.
+func (c *Collector) wrap(addr string, client ConnectBox, retry RetryPolicy) ConnectBox {
+	client = instrument(addr, client)
+	if retry.Attempts > 1 {
+		client = newRetryClient(addr, client, retry)
+	}
+	if c.loginBackoff > 0 {
+		client = newBackoffClient(addr, client, c.loginBackoff, c.loginBackoffMax)
+	}
+	if c.sessionMaxAge > 0 {
+		client = newSession(addr, client, c.sessionMaxAge)
+	}
+	return client
+}
+
+// Close logs out from all targets with active sessions.
+func (c *Collector) Close(ctx context.Context) {
+	c.mu.Lock()
+	clients := c.clients()
+	c.mu.Unlock()
+
+	c.closeSessions(ctx, clients)
+}
+
+// ServeHTTP handles requests from Prometheus. It collects metrics of the
+// requested module from the target, or takes them from the cache if the
+// target is polled in background, and writes them to the response.
 func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	target := r.URL.Query().Get("target")
-	client, ok := c.targets[target]
-	if !ok {
+	target, client, err := c.client(
+		r.URL.Query().Get("target"), r.URL.Query().Get("auth"))
+	switch {
+	case errors.Is(err, errUnknownTarget):
+		countProbe("", http.StatusBadRequest)
 		http400(w, "Unknown target")
 		return
+	case errors.Is(err, errUnknownAuth):
+		countProbe("", http.StatusBadRequest)
+		http400(w, "Unknown auth profile")
+		return
+	case errors.Is(err, errNotAllowed):
+		countProbe("", http.StatusForbidden)
+		http403(w, "Target is not allowed")
+		return
+	case err != nil:
+		log.Printf("Failed to create client for %s: %v", r.URL.Query().Get("target"), err)
+		countProbe("", http.StatusInternalServerError)
+		http500(w, "Collector error")
+		return
 	}
 
-	if err := client.Login(r.Context()); err != nil {
-		log.Printf("Failed to login: %v", err)
-		http500(w, "Collector error")
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() { countProbe(target, rec.status) }()
+	w = rec
+
+	conf := c.targetConf(target)
+	module := r.URL.Query().Get("module")
+	if module == "" {
+		module = conf.Module
+	}
+	if _, ok := c.module(module); module != "" && !ok {
+		http400(w, "Unknown module")
 		return
 	}
+
+	var g prometheus.Gatherer
+	if p := c.poller(target); p != nil && module == conf.Module {
+		g = p.snapshot(time.Now())
+	} else {
+		ctx, cancel := c.probeContext(r, target)
+		defer cancel()
+
+		start := time.Now()
+		families, err := c.scrape(ctx, target, module, client)
+		var backoffErr *backoffError
+		if errors.As(err, &backoffErr) {
+			log.Printf("Failed to scrape: %v", err)
+			retry := time.Until(backoffErr.until).Seconds()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry))))
+			http503(w, "Login backoff")
+			return
+		}
+		if errors.Is(err, errQueueTimeout) {
+			log.Printf("Failed to scrape: %v", err)
+			http503(w, "Target is busy")
+			return
+		}
+		if err != nil {
+			// Report the failed scrape with metrics, so that it can be
+			// told apart from an unavailable exporter
+			log.Printf("Failed to scrape: %v", err)
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(&scrapeFailure{duration: time.Since(start)})
+			g = reg
+		} else {
+			g = gathered(families)
+		}
+	}
+
+	h := promhttp.HandlerFor(g, promhttp.HandlerOpts{
+		ErrorLog:      log.Default(),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+	h.ServeHTTP(w, r)
+}
+
+// probeContext returns a context for the probe request. The deadline is
+// taken from the target config, or from the Prometheus scrape timeout
+// minus the offset, or from the global timeout.
+func (c *Collector) probeContext(r *http.Request, target string) (context.Context, context.CancelFunc) {
+	timeout := c.timeout
+	if t := c.targetConf(target).Timeout; t > 0 {
+		timeout = t
+	} else if h := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); h != "" {
+		secs, err := strconv.ParseFloat(h, 64)
+		if d := time.Duration(secs*float64(time.Second)) - c.timeoutOffset; err == nil && d > 0 {
+			timeout = d
+		}
+	}
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// scrapeTarget logs in to the target, collects metrics of the module,
+// and logs out. All collectors are enabled if the module is empty.
+func (c *Collector) scrapeTarget(
+	ctx context.Context,
+	target string,
+	module string,
+	client ConnectBox,
+) ([]*dto.MetricFamily, error) {
+	// Stop before the probe deadline to leave time for logout
+	collectCtx, cancel := c.collectContext(ctx)
+	defer cancel()
+
+	if err := client.Login(collectCtx); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
 	defer func() {
-		// Use a separate context to avoid cancelling logout when
-		// the request is cancelled
-		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		// Use a separate context to log out even if the request
+		// is cancelled, or collecting used up the deadline
+		ctx, cancel := c.logoutContext(ctx)
 		defer cancel()
 		if err := client.Logout(ctx); err != nil {
 			log.Printf("Failed to logout: %v", err)
 		}
 	}()
 
+	collectors, _ := c.module(module)
+	p := &probe{
+		ctx:        collectCtx,
+		target:     target,
+		client:     client,
+		collector:  c,
+		collectors: collectors,
+	}
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(p); err != nil {
+		return nil, fmt.Errorf("register probe: %w", err)
+	}
+	// Invalid metrics, like duplicates, are dropped, and the rest
+	// is returned
+	families, err := reg.Gather()
+	if err != nil {
+		log.Printf("Failed to gather metrics of %s: %v", target, err)
+	}
+	return families, nil
+}
+
+// gathered makes a prometheus.Gatherer for already gathered metrics.
+func gathered(families []*dto.MetricFamily) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return families, nil
+	})
+}
+
+// collectContext returns a context for login and collecting, that ends
+// the logout timeout before the probe deadline.
+func (c *Collector) collectContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline.Add(-c.logoutTimeout))
+}
+
+// logoutContext returns a context for logout, that is not cancelled with
+// the probe context. Logout takes not longer than the logout timeout.
+func (c *Collector) logoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), c.logoutTimeout)
+}
+
+// targetConf returns settings of the target.
+func (c *Collector) targetConf(target string) Target {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.targetConfs[target]
+}
+
+// module returns the set of collectors enabled by the module.
+func (c *Collector) module(name string) (map[string]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.modules[name]
+	return m, ok
+}
+
+// scrapeFailure reports a scrape, that failed before any data
+// was collected, e.g. on login.
+type scrapeFailure struct {
+	duration time.Duration
+}
+
+// Describe sends descriptors of the scrape metrics.
+func (f *scrapeFailure) Describe(ch chan<- *prometheus.Desc) {
+	ch <- scrapeSuccessDesc
+	ch <- scrapeDurationDesc
+}
+
+// Collect sends the failed scrape metrics.
+func (f *scrapeFailure) Collect(ch chan<- prometheus.Metric) {
+	gauge(ch, scrapeSuccessDesc, 0)
+	gauge(ch, scrapeDurationDesc, f.duration.Seconds())
+}
+
+// probe is a single scrape of a logged in target.
+type probe struct {
+	ctx       context.Context
+	target    string
+	client    ConnectBox
+	collector *Collector
+	// collectors is a set of enabled collectors, all collectors
+	// are enabled if it's nil
+	collectors map[string]bool
+}
+
+// Describe sends descriptors of all metrics, that the probe can collect.
+func (p *probe) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range descs {
+		ch <- d
+	}
+}
+
+// Collect gets data from the target and sends metrics to the channel.
+// Failed steps are reported with scrape metrics instead of failing
+// the whole scrape.
+func (p *probe) Collect(ch chan<- prometheus.Metric) {
 	// NOTE: Parallel requests are not possible due to how the auth system
 	// works - a new token is required for every request
-	reg := prometheus.NewRegistry()
-	c.collectCMSSystemInfo(r.Context(), reg, client)
-	c.collectLANUserTable(r.Context(), reg, client)
-	c.collectCMState(r.Context(), reg, client)
+	start := time.Now()
+	success := true
+	exceeded := false
+	for _, s := range collectors {
+		if !p.enabled(s.name, s.optIn) {
+			continue
+		}
+		// Skip the rest of collectors when the deadline is exceeded
+		// to log out and return partial results in time
+		err := p.ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			exceeded = true
+		}
+		if err == nil {
+			err = s.collect(p.collector, p, ch)
+		}
+		if err != nil {
+			log.Printf("Failed to collect %s: %v", s.name, err)
+			success = false
+		}
+		gauge(ch, scrapeCollectorSuccessDesc, boolToFloat(err == nil), s.name)
+	}
+	if errors.Is(p.ctx.Err(), context.DeadlineExceeded) {
+		exceeded = true
+	}
+	gauge(ch, scrapeSuccessDesc, boolToFloat(success))
+	gauge(ch, scrapeDurationDesc, time.Since(start).Seconds())
+	gauge(ch, scrapeDeadlineExceededDesc, boolToFloat(exceeded))
+}
 
-	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
-	h.ServeHTTP(w, r)
+// enabled checks if the collector runs in this probe. Opt-in collectors
+// run only when enabled by the module.
+func (p *probe) enabled(name string, optIn bool) bool {
+	if p.collectors == nil {
+		return !optIn
+	}
+	return p.collectors[name]
 }
 
-func (c *Collector) collectCMSSystemInfo(
+// collectors is a list of all collect steps in the order they run.
+var collectors = []struct {
+	name    string
+	collect stepFunc
+	// optIn collectors are not run by default, because their functions
+	// are not confirmed to exist on all firmware versions
+	optIn bool
+}{
+	{name: "global_settings", collect: step((*Collector).collectGlobalSettings)},
+	{name: "cm_system_info", collect: step((*Collector).collectCMSSystemInfo)},
+	{name: "dhcpv6_info", collect: step((*Collector).collectDHCPv6Info)},
+	{name: "wan_setting", collect: step((*Collector).collectWANSetting)},
+	{name: "ip_filtering", collect: step((*Collector).collectIPFiltering)},
+	{name: "mac_filtering", collect: step((*Collector).collectMACFiltering)},
+	{name: "forwarding", collect: step((*Collector).collectForwarding)},
+	{name: "lan_user_table", collect: step((*Collector).collectLANUserTable)},
+	{name: "cm_state", collect: step((*Collector).collectCMState)},
+	{name: "cm_status", collect: step((*Collector).collectCMStatus)},
+	{name: "mta_status", collect: step((*Collector).collectMTAStatus), optIn: true},
+	{name: "downstream_table", collect: step((*Collector).collectDownstreamTable)},
+	{name: "upstream_table", collect: step((*Collector).collectUpstreamTable)},
+	{name: "ofdm_downstream_table", collect: step((*Collector).collectOFDMDownstreamTable), optIn: true},
+	{name: "ofdma_upstream_table", collect: step((*Collector).collectOFDMAUpstreamTable), optIn: true},
+	{name: "event_log_table", collect: func(c *Collector, p *probe, ch chan<- prometheus.Metric) error {
+		return c.collectEventLogTable(p.ctx, ch, p.target, p.client)
+	}},
+	{name: "wireless_basic", collect: step((*Collector).collectWirelessBasic)},
+	{name: "wireless_client", collect: step((*Collector).collectWirelessClient)},
+}
+
+// isCollector checks if the collector with the given name exists.
+func isCollector(name string) bool {
+	for _, s := range collectors {
+		if s.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stepFunc gets data from the probed target and sends metrics
+// to the channel.
+type stepFunc func(c *Collector, p *probe, ch chan<- prometheus.Metric) error
+
+// step makes a stepFunc from a collect method, that doesn't depend
+// on the target.
+func step(fn func(
+	c *Collector,
 	ctx context.Context,
-	reg *prometheus.Registry,
-	client ConnectBox,
-) {
-	cmDocsisModeGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_cm_docsis_mode",
-		Help: "DocSis mode.",
-	}, []string{"mode"})
-	cmHardwareVersionGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_cm_hardware_version",
-		Help: "Hardware version.",
-	}, []string{"version"})
-	cmMacAddrGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_cm_mac_addr",
-		Help: "MAC address.",
-	}, []string{"addr"})
-	cmSerialNumberGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_cm_serial_number",
-		Help: "Serial number.",
-	}, []string{"sn"})
-	cmSystemUptimeGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_cm_system_uptime",
-		Help: "System uptime.",
-	}, []string{})
-	cmNetworkAccessGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_cm_network_access",
-		Help: "Network access.",
-	}, []string{})
-
-	reg.MustRegister(cmDocsisModeGauge)
-	reg.MustRegister(cmHardwareVersionGauge)
-	reg.MustRegister(cmMacAddrGauge)
-	reg.MustRegister(cmSerialNumberGauge)
-	reg.MustRegister(cmSystemUptimeGauge)
-	reg.MustRegister(cmNetworkAccessGauge)
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error,
+) stepFunc {
+	return func(c *Collector, p *probe, ch chan<- prometheus.Metric) error {
+		return fn(c, p.ctx, ch, p.client)
+	}
+}
+
+var (
+	scrapeSuccessDesc = newDesc(
+		"connect_box_scrape_success",
+		"Whether all data was successfully collected from the target.",
+	)
+	scrapeDurationDesc = newDesc(
+		"connect_box_scrape_duration_seconds",
+		"Time spent collecting data from the target.",
+	)
+	scrapeDeadlineExceededDesc = newDesc(
+		"connect_box_scrape_deadline_exceeded",
+		"Whether the scrape was cut short by the deadline.",
+	)
+	scrapeCollectorSuccessDesc = newDesc(
+		"connect_box_scrape_collector_success",
+		"Whether a collector succeeded.",
+		"collector",
+	)
+)
+
+var (
+	infoDesc = newDesc(
+		"connect_box_info",
+		"Router firmware info.",
+		"sw_version", "model", "operator_id", "access_level",
+	)
+	lockedOutDesc = newDesc(
+		"connect_box_locked_out",
+		"Web interface login lockout state.",
+	)
+)
 
+func (c *Collector) collectGlobalSettings(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data GlobalSettings
+	err := client.Get(ctx, FnGlobalSettings, &data)
+	if err != nil {
+		return fmt.Errorf("get GlobalSettings: %w", err)
+	}
+
+	gauge(ch, infoDesc, 1,
+		data.SwVersion,
+		data.Model,
+		data.OperatorID,
+		data.AccessLevel,
+	)
+	gauge(ch, lockedOutDesc, boolToFloat(data.LockedOut == LockedOutEnabled))
+
+	return nil
+}
+
+var (
+	cmDocsisModeDesc = newDesc(
+		"connect_box_cm_docsis_mode",
+		"DocSis mode.",
+		"mode",
+	)
+	cmHardwareVersionDesc = newDesc(
+		"connect_box_cm_hardware_version",
+		"Hardware version.",
+		"version",
+	)
+	cmMacAddrDesc = newDesc(
+		"connect_box_cm_mac_addr",
+		"MAC address.",
+		"addr",
+	)
+	cmSerialNumberDesc = newDesc(
+		"connect_box_cm_serial_number",
+		"Serial number.",
+		"sn",
+	)
+	cmSystemUptimeDesc = newDesc(
+		"connect_box_cm_system_uptime",
+		"System uptime.",
+	)
+	cmNetworkAccessDesc = newDesc(
+		"connect_box_cm_network_access",
+		"Network access.",
+	)
+)
+
+func (c *Collector) collectCMSSystemInfo(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
 	var data CMSystemInfo
 	err := client.Get(ctx, FnCMSystemInfo, &data)
 	if err != nil {
-		log.Printf("Failed to get CMSSystemInfo: %v", err)
-		return
+		return fmt.Errorf("get CMSSystemInfo: %w", err)
 	}
 
-	cmDocsisModeGauge.WithLabelValues(data.DocsisMode).Set(1)
-	cmHardwareVersionGauge.WithLabelValues(data.HardwareVersion).Set(1)
-	cmMacAddrGauge.WithLabelValues(data.MacAddr).Set(1)
-	cmSerialNumberGauge.WithLabelValues(data.SerialNumber).Set(1)
-	cmSystemUptimeGauge.WithLabelValues().Set(float64(data.SystemUptime))
-	var val float64
-	if data.NetworkAccess == NetworkAccessAllowed {
-		val = 1
+	gauge(ch, cmDocsisModeDesc, 1, data.DocsisMode)
+	gauge(ch, cmHardwareVersionDesc, 1, data.HardwareVersion)
+	gauge(ch, cmMacAddrDesc, 1, data.MacAddr)
+	gauge(ch, cmSerialNumberDesc, 1, data.SerialNumber)
+	gauge(ch, cmSystemUptimeDesc, float64(data.SystemUptime))
+	gauge(ch, cmNetworkAccessDesc, boolToFloat(data.NetworkAccess == NetworkAccessAllowed))
+
+	return nil
+}
+
+var (
+	ipv6PrefixDesc = newDesc(
+		"connect_box_ipv6_prefix",
+		"Delegated IPv6 prefix and LAN address assignment mode.",
+		"prefix", "mode",
+	)
+	ipv6PrefixLengthDesc = newDesc(
+		"connect_box_ipv6_prefix_length",
+		"Delegated IPv6 prefix length.",
+	)
+	ipv6PreferredLifetimeDesc = newDesc(
+		"connect_box_ipv6_prefix_preferred_lifetime_seconds",
+		"Delegated IPv6 prefix preferred lifetime.",
+	)
+	ipv6ValidLifetimeDesc = newDesc(
+		"connect_box_ipv6_prefix_valid_lifetime_seconds",
+		"Delegated IPv6 prefix valid lifetime.",
+	)
+)
+
+func (c *Collector) collectDHCPv6Info(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data DHCPv6Info
+	err := client.Get(ctx, FnDHCPv6Info, &data)
+	if err != nil {
+		return fmt.Errorf("get DHCPv6Info: %w", err)
 	}
-	cmNetworkAccessGauge.WithLabelValues().Set(val)
+
+	mode := "stateless"
+	if data.ManagedFlag == IPv6ManagedFlagOn {
+		mode = "stateful"
+	}
+	gauge(ch, ipv6PrefixDesc, 1, data.Prefix, mode)
+	if prefix, err := netip.ParsePrefix(data.Prefix); err == nil {
+		gauge(ch, ipv6PrefixLengthDesc, float64(prefix.Bits()))
+	}
+	gauge(ch, ipv6PreferredLifetimeDesc, float64(data.PreferredLifetime))
+	gauge(ch, ipv6ValidLifetimeDesc, float64(data.ValidLifetime))
+
+	return nil
 }
 
-func (c *Collector) collectLANUserTable(
+var dnsServerDesc = newDesc(
+	"connect_box_dns_server",
+	"DNS server received from the provider.",
+	"family", "ip",
+)
+
+func (c *Collector) collectWANSetting(
 	ctx context.Context,
-	reg *prometheus.Registry,
-	client ConnectBox,
-) {
-	clientGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_lan_client",
-		Help: "LAN client.",
-	}, []string{
-		"connection",
-		"interface",
-		"ipv4",
-		"hostname",
-		"mac",
-	})
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data WANSetting
+	err := client.Get(ctx, FnWANSetting, &data)
+	if err != nil {
+		return fmt.Errorf("get WANSetting: %w", err)
+	}
+
+	for _, addr := range data.IPv4DNSAddrs {
+		gauge(ch, dnsServerDesc, 1, "ipv4", addr)
+	}
+	for _, addr := range data.IPv6DNSAddrs {
+		gauge(ch, dnsServerDesc, 1, "ipv6", addr)
+	}
+
+	return nil
+}
+
+var (
+	ipFilterRuleDesc = newDesc(
+		"connect_box_ip_filter_rule",
+		"Firewall IP filter rule, 1 if enabled.",
+		"id", "protocol", "src_addr", "dst_addr", "dst_port",
+	)
+	ipFilterRulesDesc = newDesc(
+		"connect_box_ip_filter_rules",
+		"Number of firewall IP filter rules.",
+	)
+)
+
+func (c *Collector) collectIPFiltering(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data IPFiltering
+	err := client.Get(ctx, FnIPFiltering, &data)
+	if err != nil {
+		return fmt.Errorf("get IPFiltering: %w", err)
+	}
+
+	for _, r := range data.Rules {
+		gauge(ch, ipFilterRuleDesc, boolToFloat(r.Enabled),
+			r.ID,
+			protocolName(r.Protocol),
+			addrRange(r.SrcAddrStart, r.SrcAddrEnd),
+			addrRange(r.DstAddrStart, r.DstAddrEnd),
+			portRange(r.DstPortStart, r.DstPortEnd),
+		)
+	}
+	gauge(ch, ipFilterRulesDesc, float64(len(data.Rules)))
+
+	return nil
+}
+
+var (
+	accessRestrictedDesc = newDesc(
+		"connect_box_access_restricted",
+		"Device with restricted internet access, 1 if enabled.",
+		"mac", "name",
+	)
+	accessScheduleDesc = newDesc(
+		"connect_box_access_schedule",
+		"Time window when internet access restrictions apply.",
+		"mode", "window",
+	)
+)
+
+func (c *Collector) collectMACFiltering(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data MACFiltering
+	err := client.Get(ctx, FnMACFiltering, &data)
+	if err != nil {
+		return fmt.Errorf("get MACFiltering: %w", err)
+	}
+
+	for _, r := range data.Rules {
+		gauge(ch, accessRestrictedDesc, boolToFloat(r.Enabled), r.MACAddr, r.DeviceName)
+	}
+	switch data.TimeMode {
+	case TimeModeAlways:
+		gauge(ch, accessScheduleDesc, 1, "always", "")
+	case TimeModeGeneral:
+		for _, w := range data.GeneralTime {
+			gauge(ch, accessScheduleDesc, 1, "general", w)
+		}
+	case TimeModeDaily:
+		for _, w := range data.DailyTime {
+			gauge(ch, accessScheduleDesc, 1, "daily", w)
+		}
+	}
+
+	return nil
+}
+
+var (
+	portForwardingRuleDesc = newDesc(
+		"connect_box_port_forwarding_rule",
+		"Port forwarding rule, 1 if enabled.",
+		"id", "protocol", "external_port", "internal_port", "internal_ip",
+	)
+	portForwardingRulesDesc = newDesc(
+		"connect_box_port_forwarding_rules",
+		"Number of port forwarding rules.",
+	)
+)
 
-	reg.MustRegister(clientGauge)
+func (c *Collector) collectForwarding(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data Forwarding
+	err := client.Get(ctx, FnForwarding, &data)
+	if err != nil {
+		return fmt.Errorf("get Forwarding: %w", err)
+	}
+
+	for _, r := range data.Rules {
+		gauge(ch, portForwardingRuleDesc, boolToFloat(r.Enabled),
+			r.ID,
+			protocolName(r.Protocol),
+			portRange(r.ExternalPortStart, r.ExternalPortEnd),
+			portRange(r.InternalPortStart, r.InternalPortEnd),
+			r.LocalIP,
+		)
+	}
+	gauge(ch, portForwardingRulesDesc, float64(len(data.Rules)))
+
+	return nil
+}
 
+var (
+	lanClientDesc = newDesc(
+		"connect_box_lan_client",
+		"LAN client.",
+		"connection", "interface", "ipv4", "hostname", "mac",
+	)
+	lanClientSpeedDesc = newDesc(
+		"connect_box_lan_client_speed_mbps",
+		"LAN client link speed.",
+		"connection", "mac",
+	)
+	lanClientMethodDesc = newDesc(
+		"connect_box_lan_client_method",
+		"LAN client address assignment method.",
+		"connection", "mac", "method",
+	)
+	lanClientLeaseDesc = newDesc(
+		"connect_box_lan_client_lease_remaining_seconds",
+		"LAN client DHCP lease remaining time.",
+		"connection", "mac",
+	)
+)
+
+func (c *Collector) collectLANUserTable(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
 	var data LANUserTable
 	err := client.Get(ctx, FnLANUserTable, &data)
 	if err != nil {
-		log.Printf("Failed to get LANUserTable: %v", err)
-		return
+		return fmt.Errorf("get LANUserTable: %w", err)
 	}
 
-	for _, c := range data.Ethernet {
-		clientGauge.WithLabelValues(
-			"ethernet",
-			c.Interface,
-			c.IPv4Addr,
-			c.Hostname,
-			c.MACAddr,
-		).Set(1)
+	connections := []struct {
+		connection string
+		clients    []LANUserTableClientInfo
+	}{
+		{connection: "ethernet", clients: data.Ethernet},
+		{connection: "wifi", clients: data.WIFI},
 	}
-	for _, c := range data.WIFI {
-		clientGauge.WithLabelValues(
-			"wifi",
-			c.Interface,
-			c.IPv4Addr,
-			c.Hostname,
-			c.MACAddr,
-		).Set(1)
+	for _, conn := range connections {
+		for _, c := range conn.clients {
+			gauge(ch, lanClientDesc, 1,
+				conn.connection,
+				c.Interface,
+				c.IPv4Addr,
+				c.Hostname,
+				c.MACAddr,
+			)
+			if speed, err := strconv.ParseFloat(c.Speed, 64); err == nil {
+				gauge(ch, lanClientSpeedDesc, speed, conn.connection, c.MACAddr)
+			}
+
+			method := c.Method
+			switch c.Method {
+			case LANClientMethodDHCP:
+				method = "dhcp"
+			case LANClientMethodStatic:
+				method = "static"
+			}
+			gauge(ch, lanClientMethodDesc, 1, conn.connection, c.MACAddr, method)
+
+			if c.Method != LANClientMethodDHCP {
+				continue
+			}
+			if lease, err := parseLeaseTime(c.LeaseTime); err == nil {
+				gauge(ch, lanClientLeaseDesc, lease.Seconds(), conn.connection, c.MACAddr)
+			}
+		}
 	}
+
+	return nil
 }
 
+var (
+	tunnerTemperatureDesc = newDesc(
+		"connect_box_tunner_temperature",
+		"Tunner temperature.",
+	)
+	temperatureDesc = newDesc(
+		"connect_box_temperature",
+		"Temperature.",
+	)
+	operStateDesc = newDesc(
+		"connect_box_oper_state",
+		"Operational state.",
+	)
+	wanIPv4AddrDesc = newDesc(
+		"connect_box_wan_ipv4_addr",
+		"WAN IPv4 address.",
+		"ip",
+	)
+	wanIPv6AddrDesc = newDesc(
+		"connect_box_wan_ipv6_addr",
+		"WAN IPv6 address.",
+		"ip",
+	)
+)
+
 func (c *Collector) collectCMState(
 	ctx context.Context,
-	reg *prometheus.Registry,
-	client ConnectBox,
-) {
-	tunnerTemperatureGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_tunner_temperature",
-		Help: "Tunner temperature.",
-	}, []string{})
-	temperatureGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_temperature",
-		Help: "Temperature.",
-	}, []string{})
-	operStateGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_oper_state",
-		Help: "Operational state.",
-	}, []string{})
-	wanIPv4AddrGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_wan_ipv4_addr",
-		Help: "WAN IPv4 address.",
-	}, []string{"ip"})
-	wanIPv6AddrGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "connect_box_wan_ipv6_addr",
-		Help: "WAN IPv6 address.",
-	}, []string{"ip"})
-
-	reg.MustRegister(tunnerTemperatureGauge)
-	reg.MustRegister(temperatureGauge)
-	reg.MustRegister(operStateGauge)
-	reg.MustRegister(wanIPv4AddrGauge)
-	reg.MustRegister(wanIPv6AddrGauge)
-
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
 	var data CMState
 	err := client.Get(ctx, FnCMState, &data)
 	if err != nil {
-		log.Printf("Failed to get CMState: %v", err)
-		return
+		return fmt.Errorf("get CMState: %w", err)
 	}
 
-	tunnerTemperatureGauge.WithLabelValues().Set(float64(data.TunnerTemperature))
-	temperatureGauge.WithLabelValues().Set(float64(data.Temperature))
-	var val float64
-	if data.OperState == OperStateOK {
-		val = 1
-	}
-	operStateGauge.WithLabelValues().Set(val)
-	wanIPv4AddrGauge.WithLabelValues(data.WANIPv4Addr).Set(1)
+	gauge(ch, tunnerTemperatureDesc, float64(data.TunnerTemperature))
+	gauge(ch, temperatureDesc, float64(data.Temperature))
+	gauge(ch, operStateDesc, boolToFloat(data.OperState == OperStateOK))
+	gauge(ch, wanIPv4AddrDesc, 1, data.WANIPv4Addr)
 	for _, addr := range data.WANIPv6Addrs {
-		wanIPv6AddrGauge.WithLabelValues(addr).Set(1)
+		gauge(ch, wanIPv6AddrDesc, 1, addr)
+	}
+
+	return nil
+}
+
+var (
+	cmProvisionedDesc = newDesc(
+		"connect_box_cm_provisioned",
+		"Cable modem provisioning state.",
+		"state",
+	)
+	cmProvisioningStepDesc = newDesc(
+		"connect_box_cm_provisioning_step",
+		"Cable modem provisioning step number.",
+		"comment",
+	)
+	cmConfigFileDesc = newDesc(
+		"connect_box_cm_config_file",
+		"Cable modem configuration file.",
+		"file",
+	)
+	cmBPIEnabledDesc = newDesc(
+		"connect_box_cm_bpi_enabled",
+		"Cable modem baseline privacy (BPI) state.",
+	)
+)
+
+func (c *Collector) collectCMStatus(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data CMStatus
+	err := client.Get(ctx, FnCMStatus, &data)
+	if err != nil {
+		return fmt.Errorf("get CMStatus: %w", err)
+	}
+
+	gauge(ch, cmProvisionedDesc,
+		boolToFloat(data.ProvisioningState == CMProvisioningOnline),
+		data.ProvisioningState,
+	)
+	if step, err := strconv.Atoi(data.ProvisioningStep); err == nil {
+		gauge(ch, cmProvisioningStepDesc, float64(step), data.Comment)
+	}
+	gauge(ch, cmConfigFileDesc, 1, data.ConfigFile)
+	gauge(ch, cmBPIEnabledDesc, boolToFloat(data.BPIEnabled == CMBPIEnabled))
+
+	return nil
+}
+
+var (
+	mtaProvisionedDesc = newDesc(
+		"connect_box_mta_provisioned",
+		"Telephony adapter provisioning state.",
+	)
+	mtaLineEnabledDesc = newDesc(
+		"connect_box_mta_line_enabled",
+		"Telephone line enabled state.",
+		"line",
+	)
+	mtaLineRegisteredDesc = newDesc(
+		"connect_box_mta_line_registered",
+		"Telephone line registration state.",
+		"line",
+	)
+	mtaLineOffHookDesc = newDesc(
+		"connect_box_mta_line_off_hook",
+		"Telephone line hook state.",
+		"line",
+	)
+)
+
+func (c *Collector) collectMTAStatus(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data MTAStatus
+	err := client.Get(ctx, FnMTAStatus, &data)
+	if err != nil {
+		return fmt.Errorf("get MTAStatus: %w", err)
+	}
+
+	gauge(ch, mtaProvisionedDesc, boolToFloat(data.ProvisioningState == MTAProvisioningPass))
+	for _, line := range data.Lines {
+		gauge(ch, mtaLineEnabledDesc, boolToFloat(line.Enabled), line.LineID)
+		gauge(ch, mtaLineRegisteredDesc,
+			boolToFloat(line.Status == MTALineRegistered), line.LineID)
+		gauge(ch, mtaLineOffHookDesc,
+			boolToFloat(line.HookState == MTALineOffHook), line.LineID)
+	}
+
+	return nil
+}
+
+var (
+	downstreamFrequencyDesc = newDesc(
+		"connect_box_downstream_frequency_hz",
+		"Downstream channel frequency.",
+		"channel_id",
+	)
+	downstreamPowerDesc = newDesc(
+		"connect_box_downstream_power_dbmv",
+		"Downstream channel power level.",
+		"channel_id",
+	)
+	downstreamSNRDesc = newDesc(
+		"connect_box_downstream_snr_db",
+		"Downstream channel signal to noise ratio.",
+		"channel_id",
+	)
+	downstreamRxMERDesc = newDesc(
+		"connect_box_downstream_rx_mer_db",
+		"Downstream channel modulation error ratio.",
+		"channel_id",
+	)
+	downstreamModulationDesc = newDesc(
+		"connect_box_downstream_modulation",
+		"Downstream channel modulation.",
+		"channel_id", "modulation",
+	)
+	downstreamLockedDesc = newDesc(
+		"connect_box_downstream_locked",
+		"Downstream channel lock status.",
+		"channel_id", "lock",
+	)
+	downstreamCorrectedDesc = newDesc(
+		"connect_box_downstream_corrected_codewords_total",
+		"Downstream channel codewords corrected by FEC.",
+		"channel_id",
+	)
+	downstreamUncorrectedDesc = newDesc(
+		"connect_box_downstream_uncorrected_codewords_total",
+		"Downstream channel codewords that could not be corrected.",
+		"channel_id",
+	)
+)
+
+func (c *Collector) collectDownstreamTable(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data DownstreamTable
+	err := client.Get(ctx, FnDownstreamTable, &data)
+	if err != nil {
+		return fmt.Errorf("get DownstreamTable: %w", err)
+	}
+
+	for _, dc := range data.Channels {
+		gauge(ch, downstreamFrequencyDesc, float64(dc.Frequency), dc.ChannelID)
+		gauge(ch, downstreamPowerDesc, dc.Power, dc.ChannelID)
+		gauge(ch, downstreamSNRDesc, dc.SNR, dc.ChannelID)
+		gauge(ch, downstreamRxMERDesc, dc.RxMER, dc.ChannelID)
+		gauge(ch, downstreamModulationDesc, 1, dc.ChannelID, dc.Modulation)
+		gauge(ch, downstreamLockedDesc, boolToFloat(dc.IsQAMLocked), dc.ChannelID, "qam")
+		gauge(ch, downstreamLockedDesc, boolToFloat(dc.IsFECLocked), dc.ChannelID, "fec")
+		gauge(ch, downstreamLockedDesc, boolToFloat(dc.IsMPEGLocked), dc.ChannelID, "mpeg")
+		counter(ch, downstreamCorrectedDesc, float64(dc.PreRS), dc.ChannelID)
+		counter(ch, downstreamUncorrectedDesc, float64(dc.PostRS), dc.ChannelID)
+	}
+
+	return nil
+}
+
+var (
+	upstreamFrequencyDesc = newDesc(
+		"connect_box_upstream_frequency_hz",
+		"Upstream channel frequency.",
+		"channel_id",
+	)
+	upstreamPowerDesc = newDesc(
+		"connect_box_upstream_power_dbmv",
+		"Upstream channel power level.",
+		"channel_id",
+	)
+	upstreamSymbolRateDesc = newDesc(
+		"connect_box_upstream_symbol_rate",
+		"Upstream channel symbol rate in symbols per second.",
+		"channel_id",
+	)
+	upstreamModulationDesc = newDesc(
+		"connect_box_upstream_modulation",
+		"Upstream channel modulation.",
+		"channel_id", "modulation",
+	)
+	upstreamChannelTypeDesc = newDesc(
+		"connect_box_upstream_channel_type",
+		"Upstream channel type.",
+		"channel_id", "type",
+	)
+	upstreamTimeoutsDesc = newDesc(
+		"connect_box_upstream_timeouts_total",
+		"Upstream channel ranging timeouts.",
+		"channel_id", "timeout",
+	)
+)
+
+func (c *Collector) collectUpstreamTable(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data UpstreamTable
+	err := client.Get(ctx, FnUpstreamTable, &data)
+	if err != nil {
+		return fmt.Errorf("get UpstreamTable: %w", err)
+	}
+
+	for _, uc := range data.Channels {
+		gauge(ch, upstreamFrequencyDesc, float64(uc.Frequency), uc.ChannelID)
+		gauge(ch, upstreamPowerDesc, uc.Power, uc.ChannelID)
+		gauge(ch, upstreamSymbolRateDesc, float64(uc.SymbolRate), uc.ChannelID)
+		gauge(ch, upstreamModulationDesc, 1, uc.ChannelID, uc.Modulation)
+		gauge(ch, upstreamChannelTypeDesc, 1, uc.ChannelID, uc.ChannelType)
+		counter(ch, upstreamTimeoutsDesc, float64(uc.T1Timeouts), uc.ChannelID, "t1")
+		counter(ch, upstreamTimeoutsDesc, float64(uc.T2Timeouts), uc.ChannelID, "t2")
+		counter(ch, upstreamTimeoutsDesc, float64(uc.T3Timeouts), uc.ChannelID, "t3")
+		counter(ch, upstreamTimeoutsDesc, float64(uc.T4Timeouts), uc.ChannelID, "t4")
+	}
+
+	return nil
+}
+
+var (
+	ofdmDownstreamFrequencyDesc = newDesc(
+		"connect_box_ofdm_downstream_frequency_hz",
+		"OFDM downstream channel start and end frequencies.",
+		"channel_id", "edge",
+	)
+	ofdmDownstreamSubcarrierDesc = newDesc(
+		"connect_box_ofdm_downstream_active_subcarrier",
+		"OFDM downstream channel first and last active subcarriers.",
+		"channel_id", "edge",
+	)
+	ofdmDownstreamActiveSubcarriersDesc = newDesc(
+		"connect_box_ofdm_downstream_active_subcarriers",
+		"OFDM downstream channel number of active subcarriers.",
+		"channel_id",
+	)
+	ofdmDownstreamFFTSizeDesc = newDesc(
+		"connect_box_ofdm_downstream_fft_size",
+		"OFDM downstream channel FFT size.",
+		"channel_id",
+	)
+	ofdmDownstreamPLCPowerDesc = newDesc(
+		"connect_box_ofdm_downstream_plc_power_dbmv",
+		"OFDM downstream channel PLC power level.",
+		"channel_id",
+	)
+	ofdmDownstreamRxMERDesc = newDesc(
+		"connect_box_ofdm_downstream_rx_mer_db",
+		"OFDM downstream channel modulation error ratio.",
+		"channel_id",
+	)
+	ofdmDownstreamProfileDesc = newDesc(
+		"connect_box_ofdm_downstream_profile",
+		"OFDM downstream channel profile.",
+		"channel_id", "profile_id",
+	)
+	ofdmDownstreamLockedDesc = newDesc(
+		"connect_box_ofdm_downstream_locked",
+		"OFDM downstream channel lock status.",
+		"channel_id",
+	)
+)
+
+func (c *Collector) collectOFDMDownstreamTable(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data OFDMDownstreamTable
+	err := client.Get(ctx, FnOFDMDownstreamTable, &data)
+	if err != nil {
+		return fmt.Errorf("get OFDMDownstreamTable: %w", err)
+	}
+
+	for _, dc := range data.Channels {
+		id := dc.ChannelID
+		gauge(ch, ofdmDownstreamFrequencyDesc, float64(dc.StartFrequency), id, "start")
+		gauge(ch, ofdmDownstreamFrequencyDesc, float64(dc.EndFrequency), id, "end")
+		gauge(ch, ofdmDownstreamSubcarrierDesc, float64(dc.FirstActiveSubcarrier), id, "first")
+		gauge(ch, ofdmDownstreamSubcarrierDesc, float64(dc.LastActiveSubcarrier), id, "last")
+		gauge(ch, ofdmDownstreamActiveSubcarriersDesc, float64(dc.ActiveSubcarriers), id)
+		gauge(ch, ofdmDownstreamFFTSizeDesc, float64(dc.FFTSize), id)
+		gauge(ch, ofdmDownstreamPLCPowerDesc, dc.PLCPower, id)
+		gauge(ch, ofdmDownstreamRxMERDesc, dc.RxMER, id)
+		for _, profile := range dc.ProfileIDs {
+			gauge(ch, ofdmDownstreamProfileDesc, 1, id, profile)
+		}
+		gauge(ch, ofdmDownstreamLockedDesc, boolToFloat(dc.IsLocked), id)
 	}
+
+	return nil
+}
+
+var (
+	ofdmaUpstreamFrequencyDesc = newDesc(
+		"connect_box_ofdma_upstream_frequency_hz",
+		"OFDMA upstream channel start and end frequencies.",
+		"channel_id", "edge",
+	)
+	ofdmaUpstreamSubcarrierDesc = newDesc(
+		"connect_box_ofdma_upstream_active_subcarrier",
+		"OFDMA upstream channel first and last active subcarriers.",
+		"channel_id", "edge",
+	)
+	ofdmaUpstreamActiveSubcarriersDesc = newDesc(
+		"connect_box_ofdma_upstream_active_subcarriers",
+		"OFDMA upstream channel number of active subcarriers.",
+		"channel_id",
+	)
+	ofdmaUpstreamFFTSizeDesc = newDesc(
+		"connect_box_ofdma_upstream_fft_size",
+		"OFDMA upstream channel FFT size.",
+		"channel_id",
+	)
+	ofdmaUpstreamPowerDesc = newDesc(
+		"connect_box_ofdma_upstream_power_dbmv",
+		"OFDMA upstream channel power level.",
+		"channel_id",
+	)
+	ofdmaUpstreamProfileDesc = newDesc(
+		"connect_box_ofdma_upstream_profile",
+		"OFDMA upstream channel profile.",
+		"channel_id", "profile_id",
+	)
+	ofdmaUpstreamRangedDesc = newDesc(
+		"connect_box_ofdma_upstream_ranged",
+		"OFDMA upstream channel ranging status.",
+		"channel_id",
+	)
+)
+
+func (c *Collector) collectOFDMAUpstreamTable(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data OFDMAUpstreamTable
+	err := client.Get(ctx, FnOFDMAUpstreamTable, &data)
+	if err != nil {
+		return fmt.Errorf("get OFDMAUpstreamTable: %w", err)
+	}
+
+	for _, uc := range data.Channels {
+		id := uc.ChannelID
+		gauge(ch, ofdmaUpstreamFrequencyDesc, float64(uc.StartFrequency), id, "start")
+		gauge(ch, ofdmaUpstreamFrequencyDesc, float64(uc.EndFrequency), id, "end")
+		gauge(ch, ofdmaUpstreamSubcarrierDesc, float64(uc.FirstActiveSubcarrier), id, "first")
+		gauge(ch, ofdmaUpstreamSubcarrierDesc, float64(uc.LastActiveSubcarrier), id, "last")
+		gauge(ch, ofdmaUpstreamActiveSubcarriersDesc, float64(uc.ActiveSubcarriers), id)
+		gauge(ch, ofdmaUpstreamFFTSizeDesc, float64(uc.FFTSize), id)
+		gauge(ch, ofdmaUpstreamPowerDesc, uc.Power, id)
+		for _, profile := range uc.ProfileIDs {
+			gauge(ch, ofdmaUpstreamProfileDesc, 1, id, profile)
+		}
+		gauge(ch, ofdmaUpstreamRangedDesc, boolToFloat(uc.IsRanged), id)
+	}
+
+	return nil
+}
+
+var (
+	eventsDesc = newDesc(
+		"connect_box_events_total",
+		"Events from the cable modem event log.",
+		"priority", "event_id",
+	)
+	lastEventDesc = newDesc(
+		"connect_box_last_event_timestamp_seconds",
+		"Timestamp of the latest event in the cable modem event log.",
+	)
+)
+
+func (c *Collector) collectEventLogTable(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	target string,
+	client ConnectBox,
+) error {
+	var data EventLogTable
+	err := client.Get(ctx, FnEventLogTable, &data)
+	if err != nil {
+		return fmt.Errorf("get EventLogTable: %w", err)
+	}
+
+	// Copy the counts to avoid holding the lock while sending metrics
+	c.mu.Lock()
+	if c.events == nil {
+		c.events = map[string]*eventLog{}
+	}
+	events, ok := c.events[target]
+	if !ok {
+		events = newEventLog()
+		c.events[target] = events
+	}
+	events.update(data.Events)
+	counts := make(map[eventKey]int, len(events.counts))
+	for key, n := range events.counts {
+		counts[key] = n
+	}
+	last := events.last
+	c.mu.Unlock()
+
+	for key, n := range counts {
+		counter(ch, eventsDesc, float64(n), key.priority, key.id)
+	}
+	if last > 0 {
+		gauge(ch, lastEventDesc, float64(last))
+	}
+
+	return nil
+}
+
+var (
+	wifiEnabledDesc = newDesc(
+		"connect_box_wifi_enabled",
+		"Wi-Fi radio enabled state.",
+		"band",
+	)
+	wifiChannelDesc = newDesc(
+		"connect_box_wifi_channel",
+		"Wi-Fi current channel.",
+		"band",
+	)
+	wifiAutoChannelDesc = newDesc(
+		"connect_box_wifi_auto_channel",
+		"Wi-Fi automatic channel selection.",
+		"band",
+	)
+	wifiSSIDBroadcastDesc = newDesc(
+		"connect_box_wifi_ssid_broadcast",
+		"Wi-Fi SSID broadcast.",
+		"band",
+	)
+	wifiInfoDesc = newDesc(
+		"connect_box_wifi_info",
+		"Wi-Fi radio settings.",
+		"band", "ssid", "channel_width", "transmission_mode", "security_mode",
+	)
+)
+
+func (c *Collector) collectWirelessBasic(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data WirelessBasic
+	err := client.Get(ctx, FnWirelessBasic, &data)
+	if err != nil {
+		return fmt.Errorf("get WirelessBasic: %w", err)
+	}
+
+	bands := []struct {
+		band             string
+		enabled          string
+		ssid             string
+		hidden           string
+		channelWidth     string
+		transmissionMode string
+		securityMode     string
+		channelSetting   string
+		channel          int
+	}{
+		{
+			band:             "2.4GHz",
+			enabled:          data.BSSEnable2G,
+			ssid:             data.SSID2G,
+			hidden:           data.HideNetwork2G,
+			channelWidth:     data.BandWidth2G,
+			transmissionMode: data.TransmissionMode2G,
+			securityMode:     data.SecurityMode2G,
+			channelSetting:   data.ChannelSetting2G,
+			channel:          data.CurrentChannel2G,
+		},
+		{
+			band:             "5GHz",
+			enabled:          data.BSSEnable5G,
+			ssid:             data.SSID5G,
+			hidden:           data.HideNetwork5G,
+			channelWidth:     data.BandWidth5G,
+			transmissionMode: data.TransmissionMode5G,
+			securityMode:     data.SecurityMode5G,
+			channelSetting:   data.ChannelSetting5G,
+			channel:          data.CurrentChannel5G,
+		},
+	}
+	for _, b := range bands {
+		gauge(ch, wifiEnabledDesc, boolToFloat(b.enabled == WirelessEnabled), b.band)
+		gauge(ch, wifiChannelDesc, float64(b.channel), b.band)
+		gauge(ch, wifiAutoChannelDesc,
+			boolToFloat(b.channelSetting == WirelessChannelAuto), b.band)
+		gauge(ch, wifiSSIDBroadcastDesc, boolToFloat(b.hidden != WirelessHidden), b.band)
+		gauge(ch, wifiInfoDesc, 1,
+			b.band,
+			b.ssid,
+			b.channelWidth,
+			b.transmissionMode,
+			b.securityMode,
+		)
+	}
+
+	return nil
+}
+
+var (
+	wifiClientRSSIDesc = newDesc(
+		"connect_box_wifi_client_rssi_dbm",
+		"Wi-Fi client received signal strength.",
+		"band", "mac",
+	)
+	wifiClientTxRateDesc = newDesc(
+		"connect_box_wifi_client_tx_rate_mbps",
+		"Wi-Fi client negotiated transmit rate.",
+		"band", "mac",
+	)
+	wifiClientRxRateDesc = newDesc(
+		"connect_box_wifi_client_rx_rate_mbps",
+		"Wi-Fi client negotiated receive rate.",
+		"band", "mac",
+	)
+)
+
+func (c *Collector) collectWirelessClient(
+	ctx context.Context,
+	ch chan<- prometheus.Metric,
+	client ConnectBox,
+) error {
+	var data WirelessClient
+	err := client.Get(ctx, FnWirelessClient, &data)
+	if err != nil {
+		return fmt.Errorf("get WirelessClient: %w", err)
+	}
+
+	bands := []struct {
+		band    string
+		clients []WirelessClientInfo
+	}{
+		{band: "2.4GHz", clients: data.Clients2G},
+		{band: "5GHz", clients: data.Clients5G},
+	}
+	for _, b := range bands {
+		for _, c := range b.clients {
+			gauge(ch, wifiClientRSSIDesc, float64(c.RSSI), b.band, c.MACAddr)
+			gauge(ch, wifiClientTxRateDesc, float64(c.PhyRateTx), b.band, c.MACAddr)
+			gauge(ch, wifiClientRxRateDesc, float64(c.PhyRateRx), b.band, c.MACAddr)
+		}
+	}
+
+	return nil
+}
+
+// newDesc creates a metric descriptor and adds it to the list
+// of descriptors exposed by the collector.
+func newDesc(name, help string, labels ...string) *prometheus.Desc {
+	d := prometheus.NewDesc(name, help, labels, nil)
+	descs = append(descs, d)
+	return d
+}
+
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, val float64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, val, labels...)
+}
+
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, val float64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, val, labels...)
+}
+
+func protocolName(code string) string {
+	switch code {
+	case ProtocolTCP:
+		return "tcp"
+	case ProtocolUDP:
+		return "udp"
+	case ProtocolBoth:
+		return "tcp+udp"
+	}
+	return code
+}
+
+func portRange(start, end int) string {
+	if end == 0 || start == end {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "-" + strconv.Itoa(end)
+}
+
+func addrRange(start, end string) string {
+	if end == "" || start == end {
+		return start
+	}
+	return start + "-" + end
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 func http400(w http.ResponseWriter, resp string) {
@@ -215,7 +1446,17 @@ func http400(w http.ResponseWriter, resp string) {
 	w.Write([]byte(resp)) //nolint:errcheck,gosec
 }
 
+func http403(w http.ResponseWriter, resp string) {
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte(resp)) //nolint:errcheck,gosec
+}
+
 func http500(w http.ResponseWriter, resp string) {
 	w.WriteHeader(http.StatusInternalServerError)
 	w.Write([]byte(resp)) //nolint:errcheck,gosec
 }
+
+func http503(w http.ResponseWriter, resp string) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(resp)) //nolint:errcheck,gosec
+}
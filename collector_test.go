@@ -7,10 +7,13 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/tetafro/connectbox"
 	"go.uber.org/mock/gomock"
@@ -18,10 +21,17 @@ import (
 
 func TestNewCollector(t *testing.T) {
 	c := NewCollector(
-		3*time.Second,
+		Config{Timeout: 3 * time.Second},
 		map[string]ConnectBox{"test": &connectbox.Client{}},
 	)
 	require.Len(t, c.targets, 1)
+	require.IsType(t, &instrumentedClient{}, c.targets["test"])
+
+	c = NewCollector(
+		Config{Timeout: 3 * time.Second, SessionMaxAge: time.Minute},
+		map[string]ConnectBox{"test": &connectbox.Client{}},
+	)
+	require.IsType(t, &session{}, c.targets["test"])
 }
 
 func TestCollector_ServeHTTP(t *testing.T) {
@@ -34,6 +44,19 @@ func TestCollector_ServeHTTP(t *testing.T) {
 
 		metrics.EXPECT().Login(gomock.Any()).Return(nil)
 
+		var globalSettingsData GlobalSettings
+		metrics.EXPECT().Get(
+			gomock.Any(), FnGlobalSettings, &globalSettingsData,
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*GlobalSettings)
+			data.SwVersion = "SwVersion"
+			data.Model = "Model"
+			data.OperatorID = "OperatorID"
+			data.AccessLevel = "AccessLevel"
+			data.LockedOut = "Disable"
+			return nil
+		})
+
 		var cmSystemInfoData CMSystemInfo
 		metrics.EXPECT().Get(
 			gomock.Any(), FnCMSystemInfo, &cmSystemInfoData,
@@ -48,6 +71,80 @@ func TestCollector_ServeHTTP(t *testing.T) {
 			return nil
 		})
 
+		var dhcpv6InfoData DHCPv6Info
+		metrics.EXPECT().Get(
+			gomock.Any(), FnDHCPv6Info, &dhcpv6InfoData,
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*DHCPv6Info)
+			data.ManagedFlag = "0"
+			data.Prefix = "2001:db8:1234::/56"
+			data.PreferredLifetime = 3600
+			data.ValidLifetime = 7200
+			return nil
+		})
+
+		var wanSettingData WANSetting
+		metrics.EXPECT().Get(
+			gomock.Any(), FnWANSetting, &wanSettingData,
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*WANSetting)
+			data.IPv4DNSAddrs = []string{"1.1.1.1"}
+			data.IPv6DNSAddrs = []string{"2001:db8::1"}
+			return nil
+		})
+
+		var ipFilteringData IPFiltering
+		metrics.EXPECT().Get(
+			gomock.Any(), FnIPFiltering, &ipFilteringData,
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*IPFiltering)
+			data.Rules = []IPFilteringRule{{
+				ID:           "1",
+				SrcAddrStart: "192.168.0.10",
+				SrcAddrEnd:   "192.168.0.20",
+				DstAddrStart: "1.1.1.1",
+				DstAddrEnd:   "1.1.1.1",
+				Protocol:     ProtocolUDP,
+				DstPortStart: 53,
+				DstPortEnd:   53,
+				Enabled:      true,
+			}}
+			return nil
+		})
+
+		metrics.EXPECT().Get(gomock.Any(), FnMACFiltering, gomock.Any()).
+			Return(nil)
+
+		var forwardingData Forwarding
+		metrics.EXPECT().Get(
+			gomock.Any(), FnForwarding, &forwardingData,
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*Forwarding)
+			data.Rules = []ForwardingRule{
+				{
+					ID:                "1",
+					LocalIP:           "192.168.0.10",
+					ExternalPortStart: 8080,
+					ExternalPortEnd:   8080,
+					InternalPortStart: 80,
+					InternalPortEnd:   80,
+					Protocol:          ProtocolTCP,
+					Enabled:           true,
+				},
+				{
+					ID:                "2",
+					LocalIP:           "192.168.0.11",
+					ExternalPortStart: 6881,
+					ExternalPortEnd:   6889,
+					InternalPortStart: 6881,
+					InternalPortEnd:   6889,
+					Protocol:          ProtocolBoth,
+					Enabled:           false,
+				},
+			}
+			return nil
+		})
+
 		var lanUserTableData LANUserTable
 		metrics.EXPECT().Get(
 			gomock.Any(), FnLANUserTable, &lanUserTableData,
@@ -60,9 +157,9 @@ func TestCollector_ServeHTTP(t *testing.T) {
 				InterfaceID: "EthernetInterfaceID",
 				Hostname:    "EthernetHostname",
 				MACAddr:     "EthernetMACAddr",
-				Method:      "EthernetMethod",
-				LeaseTime:   "EthernetLeaseTime",
-				Speed:       "EthernetSpeed",
+				Method:      LANClientMethodDHCP,
+				LeaseTime:   "00:01:02:03",
+				Speed:       "1000",
 			}}
 			data.WIFI = []LANUserTableClientInfo{{
 				Interface:   "WIFIInterface",
@@ -91,6 +188,52 @@ func TestCollector_ServeHTTP(t *testing.T) {
 			return nil
 		})
 
+		var cmStatusData CMStatus
+		metrics.EXPECT().Get(
+			gomock.Any(), FnCMStatus, &cmStatusData,
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*CMStatus)
+			data.ProvisioningState = CMProvisioningOnline
+			data.ProvisioningStep = "12"
+			data.Comment = "Operational"
+			data.ConfigFile = "ConfigFile"
+			data.BPIEnabled = CMBPIEnabled
+			return nil
+		})
+
+		metrics.EXPECT().Get(gomock.Any(), FnWirelessClient, gomock.Any()).
+			Return(nil)
+		metrics.EXPECT().Get(gomock.Any(), FnDownstreamTable, gomock.Any()).
+			Return(nil)
+		metrics.EXPECT().Get(gomock.Any(), FnUpstreamTable, gomock.Any()).
+			Return(nil)
+		metrics.EXPECT().Get(gomock.Any(), FnEventLogTable, gomock.Any()).
+			Return(nil)
+
+		var wirelessBasicData WirelessBasic
+		metrics.EXPECT().Get(
+			gomock.Any(), FnWirelessBasic, &wirelessBasicData,
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*WirelessBasic)
+			data.BSSEnable2G = WirelessEnabled
+			data.SSID2G = "SSID2G"
+			data.HideNetwork2G = "2"
+			data.BandWidth2G = "BandWidth2G"
+			data.TransmissionMode2G = "TransmissionMode2G"
+			data.SecurityMode2G = "SecurityMode2G"
+			data.ChannelSetting2G = WirelessChannelAuto
+			data.CurrentChannel2G = 6
+			data.BSSEnable5G = "2"
+			data.SSID5G = "SSID5G"
+			data.HideNetwork5G = WirelessHidden
+			data.BandWidth5G = "BandWidth5G"
+			data.TransmissionMode5G = "TransmissionMode5G"
+			data.SecurityMode5G = "SecurityMode5G"
+			data.ChannelSetting5G = "36"
+			data.CurrentChannel5G = 36
+			return nil
+		})
+
 		metrics.EXPECT().Logout(gomock.Any()).Return(nil)
 
 		col := &Collector{
@@ -108,6 +251,12 @@ func TestCollector_ServeHTTP(t *testing.T) {
 		require.Equal(t, http.StatusOK, rec.Code)
 
 		want := strings.Join([]string{
+			`# HELP connect_box_cm_bpi_enabled Cable modem baseline privacy (BPI) state.`,
+			`# TYPE connect_box_cm_bpi_enabled gauge`,
+			`connect_box_cm_bpi_enabled 1`,
+			`# HELP connect_box_cm_config_file Cable modem configuration file.`,
+			`# TYPE connect_box_cm_config_file gauge`,
+			`connect_box_cm_config_file{file="ConfigFile"} 1`,
 			`# HELP connect_box_cm_docsis_mode DocSis mode.`,
 			`# TYPE connect_box_cm_docsis_mode gauge`,
 			`connect_box_cm_docsis_mode{mode="DocsisMode"} 1`,
@@ -120,12 +269,47 @@ func TestCollector_ServeHTTP(t *testing.T) {
 			`# HELP connect_box_cm_network_access Network access.`,
 			`# TYPE connect_box_cm_network_access gauge`,
 			`connect_box_cm_network_access 1`,
+			`# HELP connect_box_cm_provisioned Cable modem provisioning state.`,
+			`# TYPE connect_box_cm_provisioned gauge`,
+			`connect_box_cm_provisioned{state="Online"} 1`,
+			`# HELP connect_box_cm_provisioning_step Cable modem provisioning step number.`,
+			`# TYPE connect_box_cm_provisioning_step gauge`,
+			`connect_box_cm_provisioning_step{comment="Operational"} 12`,
 			`# HELP connect_box_cm_serial_number Serial number.`,
 			`# TYPE connect_box_cm_serial_number gauge`,
 			`connect_box_cm_serial_number{sn="SerialNumber"} 1`,
 			`# HELP connect_box_cm_system_uptime System uptime.`,
 			`# TYPE connect_box_cm_system_uptime gauge`,
 			`connect_box_cm_system_uptime 100`,
+			`# HELP connect_box_dns_server DNS server received from the provider.`,
+			`# TYPE connect_box_dns_server gauge`,
+			`connect_box_dns_server{family="ipv4",ip="1.1.1.1"} 1`,
+			`connect_box_dns_server{family="ipv6",ip="2001:db8::1"} 1`,
+			`# HELP connect_box_info Router firmware info.`,
+			`# TYPE connect_box_info gauge`,
+			`connect_box_info{` +
+				`access_level="AccessLevel",model="Model",` +
+				`operator_id="OperatorID",sw_version="SwVersion"} 1`,
+			`# HELP connect_box_ip_filter_rule Firewall IP filter rule, 1 if enabled.`,
+			`# TYPE connect_box_ip_filter_rule gauge`,
+			`connect_box_ip_filter_rule{` +
+				`dst_addr="1.1.1.1",dst_port="53",id="1",protocol="udp",` +
+				`src_addr="192.168.0.10-192.168.0.20"} 1`,
+			`# HELP connect_box_ip_filter_rules Number of firewall IP filter rules.`,
+			`# TYPE connect_box_ip_filter_rules gauge`,
+			`connect_box_ip_filter_rules 1`,
+			`# HELP connect_box_ipv6_prefix Delegated IPv6 prefix and LAN address assignment mode.`,
+			`# TYPE connect_box_ipv6_prefix gauge`,
+			`connect_box_ipv6_prefix{mode="stateless",prefix="2001:db8:1234::/56"} 1`,
+			`# HELP connect_box_ipv6_prefix_length Delegated IPv6 prefix length.`,
+			`# TYPE connect_box_ipv6_prefix_length gauge`,
+			`connect_box_ipv6_prefix_length 56`,
+			`# HELP connect_box_ipv6_prefix_preferred_lifetime_seconds Delegated IPv6 prefix preferred lifetime.`,
+			`# TYPE connect_box_ipv6_prefix_preferred_lifetime_seconds gauge`,
+			`connect_box_ipv6_prefix_preferred_lifetime_seconds 3600`,
+			`# HELP connect_box_ipv6_prefix_valid_lifetime_seconds Delegated IPv6 prefix valid lifetime.`,
+			`# TYPE connect_box_ipv6_prefix_valid_lifetime_seconds gauge`,
+			`connect_box_ipv6_prefix_valid_lifetime_seconds 7200`,
 			`# HELP connect_box_lan_client LAN client.`,
 			`# TYPE connect_box_lan_client gauge`,
 			`connect_box_lan_client{` +
@@ -136,9 +320,59 @@ func TestCollector_ServeHTTP(t *testing.T) {
 				`connection="wifi",hostname="WIFIHostname",` +
 				`interface="WIFIInterface",ipv4="WIFIIPv4Addr",` +
 				`mac="WIFIMACAddr"} 1`,
+			`# HELP connect_box_lan_client_lease_remaining_seconds LAN client DHCP lease remaining time.`,
+			`# TYPE connect_box_lan_client_lease_remaining_seconds gauge`,
+			`connect_box_lan_client_lease_remaining_seconds{connection="ethernet",mac="EthernetMACAddr"} 3723`,
+			`# HELP connect_box_lan_client_method LAN client address assignment method.`,
+			`# TYPE connect_box_lan_client_method gauge`,
+			`connect_box_lan_client_method{connection="ethernet",mac="EthernetMACAddr",method="dhcp"} 1`,
+			`connect_box_lan_client_method{connection="wifi",mac="WIFIMACAddr",method="WIFIMethod"} 1`,
+			`# HELP connect_box_lan_client_speed_mbps LAN client link speed.`,
+			`# TYPE connect_box_lan_client_speed_mbps gauge`,
+			`connect_box_lan_client_speed_mbps{connection="ethernet",mac="EthernetMACAddr"} 1000`,
+			`# HELP connect_box_locked_out Web interface login lockout state.`,
+			`# TYPE connect_box_locked_out gauge`,
+			`connect_box_locked_out 0`,
 			`# HELP connect_box_oper_state Operational state.`,
 			`# TYPE connect_box_oper_state gauge`,
 			`connect_box_oper_state 1`,
+			`# HELP connect_box_port_forwarding_rule Port forwarding rule, 1 if enabled.`,
+			`# TYPE connect_box_port_forwarding_rule gauge`,
+			`connect_box_port_forwarding_rule{` +
+				`external_port="6881-6889",id="2",internal_ip="192.168.0.11",` +
+				`internal_port="6881-6889",protocol="tcp+udp"} 0`,
+			`connect_box_port_forwarding_rule{` +
+				`external_port="8080",id="1",internal_ip="192.168.0.10",` +
+				`internal_port="80",protocol="tcp"} 1`,
+			`# HELP connect_box_port_forwarding_rules Number of port forwarding rules.`,
+			`# TYPE connect_box_port_forwarding_rules gauge`,
+			`connect_box_port_forwarding_rules 2`,
+			`# HELP connect_box_scrape_collector_success Whether a collector succeeded.`,
+			`# TYPE connect_box_scrape_collector_success gauge`,
+			`connect_box_scrape_collector_success{collector="cm_state"} 1`,
+			`connect_box_scrape_collector_success{collector="cm_status"} 1`,
+			`connect_box_scrape_collector_success{collector="cm_system_info"} 1`,
+			`connect_box_scrape_collector_success{collector="dhcpv6_info"} 1`,
+			`connect_box_scrape_collector_success{collector="downstream_table"} 1`,
+			`connect_box_scrape_collector_success{collector="event_log_table"} 1`,
+			`connect_box_scrape_collector_success{collector="forwarding"} 1`,
+			`connect_box_scrape_collector_success{collector="global_settings"} 1`,
+			`connect_box_scrape_collector_success{collector="ip_filtering"} 1`,
+			`connect_box_scrape_collector_success{collector="lan_user_table"} 1`,
+			`connect_box_scrape_collector_success{collector="mac_filtering"} 1`,
+			`connect_box_scrape_collector_success{collector="upstream_table"} 1`,
+			`connect_box_scrape_collector_success{collector="wan_setting"} 1`,
+			`connect_box_scrape_collector_success{collector="wireless_basic"} 1`,
+			`connect_box_scrape_collector_success{collector="wireless_client"} 1`,
+			`# HELP connect_box_scrape_deadline_exceeded Whether the scrape was cut short by the deadline.`,
+			`# TYPE connect_box_scrape_deadline_exceeded gauge`,
+			`connect_box_scrape_deadline_exceeded 0`,
+			`# HELP connect_box_scrape_duration_seconds Time spent collecting data from the target.`,
+			`# TYPE connect_box_scrape_duration_seconds gauge`,
+			`connect_box_scrape_duration_seconds 0`,
+			`# HELP connect_box_scrape_success Whether all data was successfully collected from the target.`,
+			`# TYPE connect_box_scrape_success gauge`,
+			`connect_box_scrape_success 1`,
 			`# HELP connect_box_temperature Temperature.`,
 			`# TYPE connect_box_temperature gauge`,
 			`connect_box_temperature 20`,
@@ -151,8 +385,34 @@ func TestCollector_ServeHTTP(t *testing.T) {
 			`# HELP connect_box_wan_ipv6_addr WAN IPv6 address.`,
 			`# TYPE connect_box_wan_ipv6_addr gauge`,
 			`connect_box_wan_ipv6_addr{ip="WANIPv6Addr"} 1`,
+			`# HELP connect_box_wifi_auto_channel Wi-Fi automatic channel selection.`,
+			`# TYPE connect_box_wifi_auto_channel gauge`,
+			`connect_box_wifi_auto_channel{band="2.4GHz"} 1`,
+			`connect_box_wifi_auto_channel{band="5GHz"} 0`,
+			`# HELP connect_box_wifi_channel Wi-Fi current channel.`,
+			`# TYPE connect_box_wifi_channel gauge`,
+			`connect_box_wifi_channel{band="2.4GHz"} 6`,
+			`connect_box_wifi_channel{band="5GHz"} 36`,
+			`# HELP connect_box_wifi_enabled Wi-Fi radio enabled state.`,
+			`# TYPE connect_box_wifi_enabled gauge`,
+			`connect_box_wifi_enabled{band="2.4GHz"} 1`,
+			`connect_box_wifi_enabled{band="5GHz"} 0`,
+			`# HELP connect_box_wifi_info Wi-Fi radio settings.`,
+			`# TYPE connect_box_wifi_info gauge`,
+			`connect_box_wifi_info{` +
+				`band="2.4GHz",channel_width="BandWidth2G",` +
+				`security_mode="SecurityMode2G",ssid="SSID2G",` +
+				`transmission_mode="TransmissionMode2G"} 1`,
+			`connect_box_wifi_info{` +
+				`band="5GHz",channel_width="BandWidth5G",` +
+				`security_mode="SecurityMode5G",ssid="SSID5G",` +
+				`transmission_mode="TransmissionMode5G"} 1`,
+			`# HELP connect_box_wifi_ssid_broadcast Wi-Fi SSID broadcast.`,
+			`# TYPE connect_box_wifi_ssid_broadcast gauge`,
+			`connect_box_wifi_ssid_broadcast{band="2.4GHz"} 1`,
+			`connect_box_wifi_ssid_broadcast{band="5GHz"} 0`,
 		}, "\n") + "\n"
-		require.Equal(t, want, rec.Body.String())
+		require.Equal(t, want, stripScrapeDuration(rec.Body.String()))
 	})
 
 	t.Run("no target", func(t *testing.T) {
@@ -169,6 +429,80 @@ func TestCollector_ServeHTTP(t *testing.T) {
 		require.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 
+	t.Run("module", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		metrics := NewMockConnectBox(ctrl)
+		metrics.EXPECT().Login(gomock.Any()).Return(nil)
+		metrics.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).Return(nil)
+		metrics.EXPECT().Logout(gomock.Any()).Return(nil)
+
+		col := &Collector{
+			targets: map[string]ConnectBox{
+				"127.0.0.1": metrics,
+			},
+			modules: map[string]map[string]bool{
+				"cm_only": {"cm_state": true},
+			},
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/probe?target=127.0.0.1&module=cm_only", nil)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		col.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(),
+			`connect_box_scrape_collector_success{collector="cm_state"} 1`)
+		require.NotContains(t, rec.Body.String(),
+			`connect_box_scrape_collector_success{collector="global_settings"}`)
+	})
+
+	t.Run("opt-in collector", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		metrics := NewMockConnectBox(ctrl)
+		metrics.EXPECT().Login(gomock.Any()).Return(nil)
+		metrics.EXPECT().Get(gomock.Any(), FnOFDMDownstreamTable, gomock.Any()).Return(nil)
+		metrics.EXPECT().Logout(gomock.Any()).Return(nil)
+
+		col := &Collector{
+			targets: map[string]ConnectBox{
+				"127.0.0.1": metrics,
+			},
+			modules: map[string]map[string]bool{
+				"ofdm": {"ofdm_downstream_table": true},
+			},
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/probe?target=127.0.0.1&module=ofdm", nil)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		col.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(),
+			`connect_box_scrape_collector_success{collector="ofdm_downstream_table"} 1`)
+	})
+
+	t.Run("unknown module", func(t *testing.T) {
+		col := &Collector{
+			targets: map[string]ConnectBox{
+				"127.0.0.1": NewMockConnectBox(gomock.NewController(t)),
+			},
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/probe?target=127.0.0.1&module=cm_only", nil)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		col.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
 	t.Run("failed to login", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 
@@ -187,7 +521,17 @@ func TestCollector_ServeHTTP(t *testing.T) {
 		rec := httptest.NewRecorder()
 		col.ServeHTTP(rec, req)
 
-		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		want := strings.Join([]string{
+			`# HELP connect_box_scrape_duration_seconds Time spent collecting data from the target.`,
+			`# TYPE connect_box_scrape_duration_seconds gauge`,
+			`connect_box_scrape_duration_seconds 0`,
+			`# HELP connect_box_scrape_success Whether all data was successfully collected from the target.`,
+			`# TYPE connect_box_scrape_success gauge`,
+			`connect_box_scrape_success 0`,
+		}, "\n") + "\n"
+		require.Equal(t, want, stripScrapeDuration(rec.Body.String()))
 	})
 
 	t.Run("failed to get metrics", func(t *testing.T) {
@@ -196,12 +540,36 @@ func TestCollector_ServeHTTP(t *testing.T) {
 		metrics := NewMockConnectBox(ctrl)
 
 		metrics.EXPECT().Login(gomock.Any()).Return(nil)
+		metrics.EXPECT().Get(gomock.Any(), FnGlobalSettings, gomock.Any()).
+			Return(errors.New("fail"))
 		metrics.EXPECT().Get(gomock.Any(), FnCMSystemInfo, gomock.Any()).
 			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnDHCPv6Info, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnWANSetting, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnIPFiltering, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnForwarding, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnMACFiltering, gomock.Any()).
+			Return(errors.New("fail"))
 		metrics.EXPECT().Get(gomock.Any(), FnLANUserTable, gomock.Any()).
 			Return(errors.New("fail"))
 		metrics.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
 			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnDownstreamTable, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnUpstreamTable, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnEventLogTable, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnWirelessBasic, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnWirelessClient, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnCMStatus, gomock.Any()).
+			Return(errors.New("fail"))
 		metrics.EXPECT().Logout(gomock.Any()).Return(nil)
 
 		col := &Collector{
@@ -217,7 +585,36 @@ func TestCollector_ServeHTTP(t *testing.T) {
 		col.ServeHTTP(rec, req)
 
 		require.Equal(t, http.StatusOK, rec.Code)
-		require.Equal(t, "", rec.Body.String())
+
+		want := strings.Join([]string{
+			`# HELP connect_box_scrape_collector_success Whether a collector succeeded.`,
+			`# TYPE connect_box_scrape_collector_success gauge`,
+			`connect_box_scrape_collector_success{collector="cm_state"} 0`,
+			`connect_box_scrape_collector_success{collector="cm_status"} 0`,
+			`connect_box_scrape_collector_success{collector="cm_system_info"} 0`,
+			`connect_box_scrape_collector_success{collector="dhcpv6_info"} 0`,
+			`connect_box_scrape_collector_success{collector="downstream_table"} 0`,
+			`connect_box_scrape_collector_success{collector="event_log_table"} 0`,
+			`connect_box_scrape_collector_success{collector="forwarding"} 0`,
+			`connect_box_scrape_collector_success{collector="global_settings"} 0`,
+			`connect_box_scrape_collector_success{collector="ip_filtering"} 0`,
+			`connect_box_scrape_collector_success{collector="lan_user_table"} 0`,
+			`connect_box_scrape_collector_success{collector="mac_filtering"} 0`,
+			`connect_box_scrape_collector_success{collector="upstream_table"} 0`,
+			`connect_box_scrape_collector_success{collector="wan_setting"} 0`,
+			`connect_box_scrape_collector_success{collector="wireless_basic"} 0`,
+			`connect_box_scrape_collector_success{collector="wireless_client"} 0`,
+			`# HELP connect_box_scrape_deadline_exceeded Whether the scrape was cut short by the deadline.`,
+			`# TYPE connect_box_scrape_deadline_exceeded gauge`,
+			`connect_box_scrape_deadline_exceeded 0`,
+			`# HELP connect_box_scrape_duration_seconds Time spent collecting data from the target.`,
+			`# TYPE connect_box_scrape_duration_seconds gauge`,
+			`connect_box_scrape_duration_seconds 0`,
+			`# HELP connect_box_scrape_success Whether all data was successfully collected from the target.`,
+			`# TYPE connect_box_scrape_success gauge`,
+			`connect_box_scrape_success 0`,
+		}, "\n") + "\n"
+		require.Equal(t, want, stripScrapeDuration(rec.Body.String()))
 	})
 
 	t.Run("failed to get metrics and to logout", func(t *testing.T) {
@@ -226,12 +623,36 @@ func TestCollector_ServeHTTP(t *testing.T) {
 		metrics := NewMockConnectBox(ctrl)
 
 		metrics.EXPECT().Login(gomock.Any()).Return(nil)
+		metrics.EXPECT().Get(gomock.Any(), FnGlobalSettings, gomock.Any()).
+			Return(errors.New("fail"))
 		metrics.EXPECT().Get(gomock.Any(), FnCMSystemInfo, gomock.Any()).
 			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnDHCPv6Info, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnWANSetting, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnIPFiltering, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnForwarding, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnMACFiltering, gomock.Any()).
+			Return(errors.New("fail"))
 		metrics.EXPECT().Get(gomock.Any(), FnLANUserTable, gomock.Any()).
 			Return(errors.New("fail"))
 		metrics.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
 			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnDownstreamTable, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnUpstreamTable, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnEventLogTable, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnWirelessBasic, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnWirelessClient, gomock.Any()).
+			Return(errors.New("fail"))
+		metrics.EXPECT().Get(gomock.Any(), FnCMStatus, gomock.Any()).
+			Return(errors.New("fail"))
 		metrics.EXPECT().Logout(gomock.Any()).Return(errors.New("fail"))
 
 		col := &Collector{
@@ -247,6 +668,809 @@ func TestCollector_ServeHTTP(t *testing.T) {
 		col.ServeHTTP(rec, req)
 
 		require.Equal(t, http.StatusOK, rec.Code)
-		require.Equal(t, "", rec.Body.String())
+
+		want := strings.Join([]string{
+			`# HELP connect_box_scrape_collector_success Whether a collector succeeded.`,
+			`# TYPE connect_box_scrape_collector_success gauge`,
+			`connect_box_scrape_collector_success{collector="cm_state"} 0`,
+			`connect_box_scrape_collector_success{collector="cm_status"} 0`,
+			`connect_box_scrape_collector_success{collector="cm_system_info"} 0`,
+			`connect_box_scrape_collector_success{collector="dhcpv6_info"} 0`,
+			`connect_box_scrape_collector_success{collector="downstream_table"} 0`,
+			`connect_box_scrape_collector_success{collector="event_log_table"} 0`,
+			`connect_box_scrape_collector_success{collector="forwarding"} 0`,
+			`connect_box_scrape_collector_success{collector="global_settings"} 0`,
+			`connect_box_scrape_collector_success{collector="ip_filtering"} 0`,
+			`connect_box_scrape_collector_success{collector="lan_user_table"} 0`,
+			`connect_box_scrape_collector_success{collector="mac_filtering"} 0`,
+			`connect_box_scrape_collector_success{collector="upstream_table"} 0`,
+			`connect_box_scrape_collector_success{collector="wan_setting"} 0`,
+			`connect_box_scrape_collector_success{collector="wireless_basic"} 0`,
+			`connect_box_scrape_collector_success{collector="wireless_client"} 0`,
+			`# HELP connect_box_scrape_deadline_exceeded Whether the scrape was cut short by the deadline.`,
+			`# TYPE connect_box_scrape_deadline_exceeded gauge`,
+			`connect_box_scrape_deadline_exceeded 0`,
+			`# HELP connect_box_scrape_duration_seconds Time spent collecting data from the target.`,
+			`# TYPE connect_box_scrape_duration_seconds gauge`,
+			`connect_box_scrape_duration_seconds 0`,
+			`# HELP connect_box_scrape_success Whether all data was successfully collected from the target.`,
+			`# TYPE connect_box_scrape_success gauge`,
+			`connect_box_scrape_success 0`,
+		}, "\n") + "\n"
+		require.Equal(t, want, stripScrapeDuration(rec.Body.String()))
+	})
+}
+
+func TestCollector_collectMTAStatus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Get(
+		gomock.Any(), FnMTAStatus, gomock.Any(),
+	).Do(func(ctx context.Context, fn string, out any) error {
+		data := out.(*MTAStatus)
+		data.ProvisioningState = MTAProvisioningPass
+		data.Lines = []MTAStatusLine{
+			{
+				LineID:    "1",
+				Enabled:   true,
+				Status:    MTALineRegistered,
+				HookState: "On-Hook",
+			},
+			{
+				LineID:    "2",
+				Enabled:   false,
+				Status:    "Disabled",
+				HookState: MTALineOffHook,
+			},
+		}
+		return nil
+	})
+
+	col := &Collector{}
+	reg := newTestRegistry(func(ch chan<- prometheus.Metric) {
+		col.collectMTAStatus(context.Background(), ch, metrics)
+	})
+
+	want := strings.Join([]string{
+		`# HELP connect_box_mta_line_enabled Telephone line enabled state.`,
+		`# TYPE connect_box_mta_line_enabled gauge`,
+		`connect_box_mta_line_enabled{line="1"} 1`,
+		`connect_box_mta_line_enabled{line="2"} 0`,
+		`# HELP connect_box_mta_line_off_hook Telephone line hook state.`,
+		`# TYPE connect_box_mta_line_off_hook gauge`,
+		`connect_box_mta_line_off_hook{line="1"} 0`,
+		`connect_box_mta_line_off_hook{line="2"} 1`,
+		`# HELP connect_box_mta_line_registered Telephone line registration state.`,
+		`# TYPE connect_box_mta_line_registered gauge`,
+		`connect_box_mta_line_registered{line="1"} 1`,
+		`connect_box_mta_line_registered{line="2"} 0`,
+		`# HELP connect_box_mta_provisioned Telephony adapter provisioning state.`,
+		`# TYPE connect_box_mta_provisioned gauge`,
+		`connect_box_mta_provisioned 1`,
+	}, "\n") + "\n"
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want))
+	require.NoError(t, err)
+}
+
+func TestCollector_collectDownstreamTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Get(
+		gomock.Any(), FnDownstreamTable, gomock.Any(),
+	).Do(func(ctx context.Context, fn string, out any) error {
+		data := out.(*DownstreamTable)
+		data.Channels = []DownstreamTableChannel{{
+			ChannelID:    "1",
+			Frequency:    762000000,
+			Power:        5.5,
+			SNR:          40,
+			RxMER:        38.983,
+			Modulation:   "256qam",
+			PreRS:        100,
+			PostRS:       10,
+			IsQAMLocked:  true,
+			IsFECLocked:  true,
+			IsMPEGLocked: false,
+		}}
+		return nil
+	})
+
+	col := &Collector{}
+	reg := newTestRegistry(func(ch chan<- prometheus.Metric) {
+		col.collectDownstreamTable(context.Background(), ch, metrics)
+	})
+
+	want := strings.Join([]string{
+		`# HELP connect_box_downstream_corrected_codewords_total Downstream channel codewords corrected by FEC.`,
+		`# TYPE connect_box_downstream_corrected_codewords_total counter`,
+		`connect_box_downstream_corrected_codewords_total{channel_id="1"} 100`,
+		`# HELP connect_box_downstream_frequency_hz Downstream channel frequency.`,
+		`# TYPE connect_box_downstream_frequency_hz gauge`,
+		`connect_box_downstream_frequency_hz{channel_id="1"} 7.62e+08`,
+		`# HELP connect_box_downstream_locked Downstream channel lock status.`,
+		`# TYPE connect_box_downstream_locked gauge`,
+		`connect_box_downstream_locked{channel_id="1",lock="fec"} 1`,
+		`connect_box_downstream_locked{channel_id="1",lock="mpeg"} 0`,
+		`connect_box_downstream_locked{channel_id="1",lock="qam"} 1`,
+		`# HELP connect_box_downstream_modulation Downstream channel modulation.`,
+		`# TYPE connect_box_downstream_modulation gauge`,
+		`connect_box_downstream_modulation{channel_id="1",modulation="256qam"} 1`,
+		`# HELP connect_box_downstream_power_dbmv Downstream channel power level.`,
+		`# TYPE connect_box_downstream_power_dbmv gauge`,
+		`connect_box_downstream_power_dbmv{channel_id="1"} 5.5`,
+		`# HELP connect_box_downstream_rx_mer_db Downstream channel modulation error ratio.`,
+		`# TYPE connect_box_downstream_rx_mer_db gauge`,
+		`connect_box_downstream_rx_mer_db{channel_id="1"} 38.983`,
+		`# HELP connect_box_downstream_snr_db Downstream channel signal to noise ratio.`,
+		`# TYPE connect_box_downstream_snr_db gauge`,
+		`connect_box_downstream_snr_db{channel_id="1"} 40`,
+		`# HELP connect_box_downstream_uncorrected_codewords_total Downstream channel codewords that could not be corrected.`,
+		`# TYPE connect_box_downstream_uncorrected_codewords_total counter`,
+		`connect_box_downstream_uncorrected_codewords_total{channel_id="1"} 10`,
+	}, "\n") + "\n"
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want))
+	require.NoError(t, err)
+}
+
+func TestCollector_collectUpstreamTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Get(
+		gomock.Any(), FnUpstreamTable, gomock.Any(),
+	).Do(func(ctx context.Context, fn string, out any) error {
+		data := out.(*UpstreamTable)
+		data.Channels = []UpstreamTableChannel{{
+			ChannelID:   "1",
+			Frequency:   30800000,
+			Power:       44.5,
+			SymbolRate:  5120000,
+			Modulation:  "64qam",
+			ChannelType: "ATDMA",
+			T1Timeouts:  1,
+			T2Timeouts:  2,
+			T3Timeouts:  3,
+			T4Timeouts:  4,
+		}}
+		return nil
+	})
+
+	col := &Collector{}
+	reg := newTestRegistry(func(ch chan<- prometheus.Metric) {
+		col.collectUpstreamTable(context.Background(), ch, metrics)
+	})
+
+	want := strings.Join([]string{
+		`# HELP connect_box_upstream_channel_type Upstream channel type.`,
+		`# TYPE connect_box_upstream_channel_type gauge`,
+		`connect_box_upstream_channel_type{channel_id="1",type="ATDMA"} 1`,
+		`# HELP connect_box_upstream_frequency_hz Upstream channel frequency.`,
+		`# TYPE connect_box_upstream_frequency_hz gauge`,
+		`connect_box_upstream_frequency_hz{channel_id="1"} 3.08e+07`,
+		`# HELP connect_box_upstream_modulation Upstream channel modulation.`,
+		`# TYPE connect_box_upstream_modulation gauge`,
+		`connect_box_upstream_modulation{channel_id="1",modulation="64qam"} 1`,
+		`# HELP connect_box_upstream_power_dbmv Upstream channel power level.`,
+		`# TYPE connect_box_upstream_power_dbmv gauge`,
+		`connect_box_upstream_power_dbmv{channel_id="1"} 44.5`,
+		`# HELP connect_box_upstream_symbol_rate Upstream channel symbol rate in symbols per second.`,
+		`# TYPE connect_box_upstream_symbol_rate gauge`,
+		`connect_box_upstream_symbol_rate{channel_id="1"} 5.12e+06`,
+		`# HELP connect_box_upstream_timeouts_total Upstream channel ranging timeouts.`,
+		`# TYPE connect_box_upstream_timeouts_total counter`,
+		`connect_box_upstream_timeouts_total{channel_id="1",timeout="t1"} 1`,
+		`connect_box_upstream_timeouts_total{channel_id="1",timeout="t2"} 2`,
+		`connect_box_upstream_timeouts_total{channel_id="1",timeout="t3"} 3`,
+		`connect_box_upstream_timeouts_total{channel_id="1",timeout="t4"} 4`,
+	}, "\n") + "\n"
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want))
+	require.NoError(t, err)
+}
+
+func TestCollector_collectOFDMDownstreamTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Get(
+		gomock.Any(), FnOFDMDownstreamTable, gomock.Any(),
+	).Do(func(ctx context.Context, fn string, out any) error {
+		data := out.(*OFDMDownstreamTable)
+		data.Channels = []OFDMDownstreamTableChannel{{
+			ChannelID:             "33",
+			StartFrequency:        135000000,
+			EndFrequency:          327000000,
+			FirstActiveSubcarrier: 148,
+			LastActiveSubcarrier:  3947,
+			ActiveSubcarriers:     3800,
+			FFTSize:               4096,
+			PLCPower:              3.1,
+			RxMER:                 41.5,
+			ProfileIDs:            []string{"0", "1"},
+			IsLocked:              true,
+		}}
+		return nil
+	})
+
+	col := &Collector{}
+	reg := newTestRegistry(func(ch chan<- prometheus.Metric) {
+		col.collectOFDMDownstreamTable(context.Background(), ch, metrics)
+	})
+
+	want := strings.Join([]string{
+		`# HELP connect_box_ofdm_downstream_active_subcarrier OFDM downstream channel first and last active subcarriers.`,
+		`# TYPE connect_box_ofdm_downstream_active_subcarrier gauge`,
+		`connect_box_ofdm_downstream_active_subcarrier{channel_id="33",edge="first"} 148`,
+		`connect_box_ofdm_downstream_active_subcarrier{channel_id="33",edge="last"} 3947`,
+		`# HELP connect_box_ofdm_downstream_active_subcarriers OFDM downstream channel number of active subcarriers.`,
+		`# TYPE connect_box_ofdm_downstream_active_subcarriers gauge`,
+		`connect_box_ofdm_downstream_active_subcarriers{channel_id="33"} 3800`,
+		`# HELP connect_box_ofdm_downstream_fft_size OFDM downstream channel FFT size.`,
+		`# TYPE connect_box_ofdm_downstream_fft_size gauge`,
+		`connect_box_ofdm_downstream_fft_size{channel_id="33"} 4096`,
+		`# HELP connect_box_ofdm_downstream_frequency_hz OFDM downstream channel start and end frequencies.`,
+		`# TYPE connect_box_ofdm_downstream_frequency_hz gauge`,
+		`connect_box_ofdm_downstream_frequency_hz{channel_id="33",edge="end"} 3.27e+08`,
+		`connect_box_ofdm_downstream_frequency_hz{channel_id="33",edge="start"} 1.35e+08`,
+		`# HELP connect_box_ofdm_downstream_locked OFDM downstream channel lock status.`,
+		`# TYPE connect_box_ofdm_downstream_locked gauge`,
+		`connect_box_ofdm_downstream_locked{channel_id="33"} 1`,
+		`# HELP connect_box_ofdm_downstream_plc_power_dbmv OFDM downstream channel PLC power level.`,
+		`# TYPE connect_box_ofdm_downstream_plc_power_dbmv gauge`,
+		`connect_box_ofdm_downstream_plc_power_dbmv{channel_id="33"} 3.1`,
+		`# HELP connect_box_ofdm_downstream_profile OFDM downstream channel profile.`,
+		`# TYPE connect_box_ofdm_downstream_profile gauge`,
+		`connect_box_ofdm_downstream_profile{channel_id="33",profile_id="0"} 1`,
+		`connect_box_ofdm_downstream_profile{channel_id="33",profile_id="1"} 1`,
+		`# HELP connect_box_ofdm_downstream_rx_mer_db OFDM downstream channel modulation error ratio.`,
+		`# TYPE connect_box_ofdm_downstream_rx_mer_db gauge`,
+		`connect_box_ofdm_downstream_rx_mer_db{channel_id="33"} 41.5`,
+	}, "\n") + "\n"
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want))
+	require.NoError(t, err)
+}
+
+func TestCollector_collectOFDMAUpstreamTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Get(
+		gomock.Any(), FnOFDMAUpstreamTable, gomock.Any(),
+	).Do(func(ctx context.Context, fn string, out any) error {
+		data := out.(*OFDMAUpstreamTable)
+		data.Channels = []OFDMAUpstreamTableChannel{{
+			ChannelID:             "9",
+			StartFrequency:        29800000,
+			EndFrequency:          64800000,
+			FirstActiveSubcarrier: 74,
+			LastActiveSubcarrier:  1275,
+			ActiveSubcarriers:     1200,
+			FFTSize:               2048,
+			Power:                 42,
+			ProfileIDs:            []string{"5"},
+			IsRanged:              true,
+		}}
+		return nil
+	})
+
+	col := &Collector{}
+	reg := newTestRegistry(func(ch chan<- prometheus.Metric) {
+		col.collectOFDMAUpstreamTable(context.Background(), ch, metrics)
+	})
+
+	want := strings.Join([]string{
+		`# HELP connect_box_ofdma_upstream_active_subcarrier OFDMA upstream channel first and last active subcarriers.`,
+		`# TYPE connect_box_ofdma_upstream_active_subcarrier gauge`,
+		`connect_box_ofdma_upstream_active_subcarrier{channel_id="9",edge="first"} 74`,
+		`connect_box_ofdma_upstream_active_subcarrier{channel_id="9",edge="last"} 1275`,
+		`# HELP connect_box_ofdma_upstream_active_subcarriers OFDMA upstream channel number of active subcarriers.`,
+		`# TYPE connect_box_ofdma_upstream_active_subcarriers gauge`,
+		`connect_box_ofdma_upstream_active_subcarriers{channel_id="9"} 1200`,
+		`# HELP connect_box_ofdma_upstream_fft_size OFDMA upstream channel FFT size.`,
+		`# TYPE connect_box_ofdma_upstream_fft_size gauge`,
+		`connect_box_ofdma_upstream_fft_size{channel_id="9"} 2048`,
+		`# HELP connect_box_ofdma_upstream_frequency_hz OFDMA upstream channel start and end frequencies.`,
+		`# TYPE connect_box_ofdma_upstream_frequency_hz gauge`,
+		`connect_box_ofdma_upstream_frequency_hz{channel_id="9",edge="end"} 6.48e+07`,
+		`connect_box_ofdma_upstream_frequency_hz{channel_id="9",edge="start"} 2.98e+07`,
+		`# HELP connect_box_ofdma_upstream_power_dbmv OFDMA upstream channel power level.`,
+		`# TYPE connect_box_ofdma_upstream_power_dbmv gauge`,
+		`connect_box_ofdma_upstream_power_dbmv{channel_id="9"} 42`,
+		`# HELP connect_box_ofdma_upstream_profile OFDMA upstream channel profile.`,
+		`# TYPE connect_box_ofdma_upstream_profile gauge`,
+		`connect_box_ofdma_upstream_profile{channel_id="9",profile_id="5"} 1`,
+		`# HELP connect_box_ofdma_upstream_ranged OFDMA upstream channel ranging status.`,
+		`# TYPE connect_box_ofdma_upstream_ranged gauge`,
+		`connect_box_ofdma_upstream_ranged{channel_id="9"} 1`,
+	}, "\n") + "\n"
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want))
+	require.NoError(t, err)
+}
+
+func TestCollector_collectEventLogTable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	t3 := EventLogTableEvent{
+		Priority:  "critical",
+		Text:      "No Ranging Response received - T3 time-out;CM-MAC=00:00:00:00:00:00;",
+		Time:      "17/10/2026 10:00:00",
+		Timestamp: 1792231200,
+	}
+	t4 := EventLogTableEvent{
+		Priority: "critical",
+		Text: "Received Response to Broadcast Maintenance Request, " +
+			"But no Unicast Maintenance opportunities received - T4 time out;" +
+			"CM-MAC=00:00:00:00:00:00;",
+		Time:      "17/10/2026 10:05:00",
+		Timestamp: 1792231500,
+	}
+	t3Again := t3
+	t3Again.Time = "17/10/2026 10:10:00"
+	t3Again.Timestamp = 1792231800
+
+	metrics := NewMockConnectBox(ctrl)
+	gomock.InOrder(
+		metrics.EXPECT().Get(
+			gomock.Any(), FnEventLogTable, gomock.Any(),
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*EventLogTable)
+			data.Events = []EventLogTableEvent{t3, t4}
+			return nil
+		}),
+		metrics.EXPECT().Get(
+			gomock.Any(), FnEventLogTable, gomock.Any(),
+		).Do(func(ctx context.Context, fn string, out any) error {
+			data := out.(*EventLogTable)
+			data.Events = []EventLogTableEvent{t3, t4, t3Again}
+			return nil
+		}),
+	)
+
+	col := &Collector{}
+	collect := func(ch chan<- prometheus.Metric) {
+		col.collectEventLogTable(context.Background(), ch, "127.0.0.1", metrics)
+	}
+	_, err := newTestRegistry(collect).Gather()
+	require.NoError(t, err)
+
+	reg := newTestRegistry(collect)
+
+	want := strings.Join([]string{
+		`# HELP connect_box_events_total Events from the cable modem event log.`,
+		`# TYPE connect_box_events_total counter`,
+		`connect_box_events_total{` +
+			`event_id="No Ranging Response received - T3 time-out",` +
+			`priority="critical"} 2`,
+		`connect_box_events_total{` +
+			`event_id="Received Response to Broadcast Maintenance Request, ` +
+			`But no Unicast Maintenance opportunities received - T4 time out",` +
+			`priority="critical"} 1`,
+		`# HELP connect_box_last_event_timestamp_seconds Timestamp of the latest event in the cable modem event log.`,
+		`# TYPE connect_box_last_event_timestamp_seconds gauge`,
+		`connect_box_last_event_timestamp_seconds 1.7922318e+09`,
+	}, "\n") + "\n"
+	err = testutil.GatherAndCompare(reg, strings.NewReader(want))
+	require.NoError(t, err)
+}
+
+func TestCollector_collectWirelessClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Get(
+		gomock.Any(), FnWirelessClient, gomock.Any(),
+	).Do(func(ctx context.Context, fn string, out any) error {
+		data := out.(*WirelessClient)
+		data.Clients2G = []WirelessClientInfo{{
+			SSID:      "SSID2G",
+			MACAddr:   "00:00:00:00:00:01",
+			PhyRateTx: 72,
+			PhyRateRx: 65,
+			PhyMode:   "11n",
+			RSSI:      -71,
+		}}
+		data.Clients5G = []WirelessClientInfo{{
+			SSID:      "SSID5G",
+			MACAddr:   "00:00:00:00:00:02",
+			PhyRateTx: 866,
+			PhyRateRx: 780,
+			PhyMode:   "11ac",
+			RSSI:      -48,
+		}}
+		return nil
+	})
+
+	col := &Collector{}
+	reg := newTestRegistry(func(ch chan<- prometheus.Metric) {
+		col.collectWirelessClient(context.Background(), ch, metrics)
+	})
+
+	want := strings.Join([]string{
+		`# HELP connect_box_wifi_client_rssi_dbm Wi-Fi client received signal strength.`,
+		`# TYPE connect_box_wifi_client_rssi_dbm gauge`,
+		`connect_box_wifi_client_rssi_dbm{band="2.4GHz",mac="00:00:00:00:00:01"} -71`,
+		`connect_box_wifi_client_rssi_dbm{band="5GHz",mac="00:00:00:00:00:02"} -48`,
+		`# HELP connect_box_wifi_client_rx_rate_mbps Wi-Fi client negotiated receive rate.`,
+		`# TYPE connect_box_wifi_client_rx_rate_mbps gauge`,
+		`connect_box_wifi_client_rx_rate_mbps{band="2.4GHz",mac="00:00:00:00:00:01"} 65`,
+		`connect_box_wifi_client_rx_rate_mbps{band="5GHz",mac="00:00:00:00:00:02"} 780`,
+		`# HELP connect_box_wifi_client_tx_rate_mbps Wi-Fi client negotiated transmit rate.`,
+		`# TYPE connect_box_wifi_client_tx_rate_mbps gauge`,
+		`connect_box_wifi_client_tx_rate_mbps{band="2.4GHz",mac="00:00:00:00:00:01"} 72`,
+		`connect_box_wifi_client_tx_rate_mbps{band="5GHz",mac="00:00:00:00:00:02"} 866`,
+	}, "\n") + "\n"
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want))
+	require.NoError(t, err)
+}
+
+func TestCollector_collectMACFiltering(t *testing.T) {
+	testCases := []struct {
+		name string
+		data MACFiltering
+		want []string
+	}{
+		{
+			name: "general schedule",
+			data: MACFiltering{
+				TimeMode:    TimeModeGeneral,
+				GeneralTime: []string{"08:00-12:00", "13:00-20:00"},
+				Rules: []MACFilteringRule{
+					{
+						ID:         "1",
+						MACAddr:    "00:00:00:00:00:01",
+						DeviceName: "tablet",
+						Enabled:    true,
+					},
+					{
+						ID:         "2",
+						MACAddr:    "00:00:00:00:00:02",
+						DeviceName: "console",
+						Enabled:    false,
+					},
+				},
+			},
+			want: []string{
+				`# HELP connect_box_access_restricted Device with restricted internet access, 1 if enabled.`,
+				`# TYPE connect_box_access_restricted gauge`,
+				`connect_box_access_restricted{mac="00:00:00:00:00:01",name="tablet"} 1`,
+				`connect_box_access_restricted{mac="00:00:00:00:00:02",name="console"} 0`,
+				`# HELP connect_box_access_schedule Time window when internet access restrictions apply.`,
+				`# TYPE connect_box_access_schedule gauge`,
+				`connect_box_access_schedule{mode="general",window="08:00-12:00"} 1`,
+				`connect_box_access_schedule{mode="general",window="13:00-20:00"} 1`,
+			},
+		},
+		{
+			name: "always",
+			data: MACFiltering{
+				TimeMode: TimeModeAlways,
+				Rules: []MACFilteringRule{{
+					ID:         "1",
+					MACAddr:    "00:00:00:00:00:01",
+					DeviceName: "tablet",
+					Enabled:    true,
+				}},
+			},
+			want: []string{
+				`# HELP connect_box_access_restricted Device with restricted internet access, 1 if enabled.`,
+				`# TYPE connect_box_access_restricted gauge`,
+				`connect_box_access_restricted{mac="00:00:00:00:00:01",name="tablet"} 1`,
+				`# HELP connect_box_access_schedule Time window when internet access restrictions apply.`,
+				`# TYPE connect_box_access_schedule gauge`,
+				`connect_box_access_schedule{mode="always",window=""} 1`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			metrics := NewMockConnectBox(ctrl)
+			metrics.EXPECT().Get(
+				gomock.Any(), FnMACFiltering, gomock.Any(),
+			).Do(func(ctx context.Context, fn string, out any) error {
+				*out.(*MACFiltering) = tc.data
+				return nil
+			})
+
+			col := &Collector{}
+			reg := newTestRegistry(func(ch chan<- prometheus.Metric) {
+				col.collectMACFiltering(context.Background(), ch, metrics)
+			})
+
+			want := strings.Join(tc.want, "\n") + "\n"
+			err := testutil.GatherAndCompare(reg, strings.NewReader(want))
+			require.NoError(t, err)
+		})
+	}
+}
+
+// testCollector is an unchecked prometheus.Collector that calls
+// a single collect step.
+type testCollector func(ch chan<- prometheus.Metric)
+
+func (testCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (f testCollector) Collect(ch chan<- prometheus.Metric) { f(ch) }
+
+func newTestRegistry(collect func(ch chan<- prometheus.Metric)) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(testCollector(collect))
+	return reg
+}
+
+var scrapeDurationRe = regexp.MustCompile(`(?m)^connect_box_scrape_duration_seconds .+$`)
+
+// stripScrapeDuration replaces the scrape duration value, that differs
+// from run to run, with zero.
+func stripScrapeDuration(s string) string {
+	return scrapeDurationRe.ReplaceAllString(s, "connect_box_scrape_duration_seconds 0")
+}
+
+// defaultCollectors returns the number of collectors run without a module.
+func defaultCollectors() int {
+	var n int
+	for _, s := range collectors {
+		if !s.optIn {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCollector_probeContext(t *testing.T) {
+	testCases := []struct {
+		name    string
+		header  string
+		target  Target
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{
+			name:    "scrape timeout header",
+			header:  "10",
+			timeout: 30 * time.Second,
+			want:    9500 * time.Millisecond,
+		},
+		{
+			name:    "target timeout",
+			header:  "10",
+			target:  Target{Timeout: 5 * time.Second},
+			timeout: 30 * time.Second,
+			want:    5 * time.Second,
+		},
+		{
+			name:    "no header",
+			timeout: 30 * time.Second,
+			want:    30 * time.Second,
+		},
+		{
+			name:    "invalid header",
+			header:  "ten",
+			timeout: 30 * time.Second,
+			want:    30 * time.Second,
+		},
+		{
+			name:    "header less than offset",
+			header:  "0.1",
+			timeout: 30 * time.Second,
+			want:    30 * time.Second,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			col := &Collector{
+				timeout:       tt.timeout,
+				timeoutOffset: 500 * time.Millisecond,
+				targetConfs:   map[string]Target{"127.0.0.1": tt.target},
+			}
+
+			req, err := http.NewRequest(http.MethodGet, "/probe?target=127.0.0.1", nil)
+			require.NoError(t, err)
+			if tt.header != "" {
+				req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tt.header)
+			}
+
+			ctx, cancel := col.probeContext(req, "127.0.0.1")
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.WithinDuration(t, time.Now().Add(tt.want), deadline, time.Second)
+		})
+	}
+}
+
+func TestCollector_scrapeTarget_duplicates(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	ctrl := gomock.NewController(t)
+
+	// The same client is listed twice
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Login(gomock.Any()).Return(nil)
+	metrics.EXPECT().Get(gomock.Any(), FnLANUserTable, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn string, out any) error {
+			client := LANUserTableClientInfo{
+				MACAddr: "00:00:00:00:00:01",
+				Method:  LANClientMethodStatic,
+			}
+			data := out.(*LANUserTable)
+			data.Ethernet = []LANUserTableClientInfo{client, client}
+			return nil
+		})
+	metrics.EXPECT().Logout(gomock.Any()).Return(nil)
+
+	col := &Collector{
+		modules: map[string]map[string]bool{
+			"lan": {"lan_user_table": true},
+		},
+	}
+
+	families, err := col.scrapeTarget(context.Background(), "127.0.0.1", "lan", metrics)
+	require.NoError(t, err)
+
+	want := strings.Join([]string{
+		`# HELP connect_box_lan_client_method LAN client address assignment method.`,
+		`# TYPE connect_box_lan_client_method gauge`,
+		`connect_box_lan_client_method{connection="ethernet",mac="00:00:00:00:00:01",method="static"} 1`,
+	}, "\n") + "\n"
+	err = testutil.GatherAndCompare(gathered(families), strings.NewReader(want),
+		"connect_box_lan_client_method")
+	require.NoError(t, err)
+}
+
+func TestProbe_Collect_deadline(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	ctrl := gomock.NewController(t)
+
+	// Collectors after the first one are skipped
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Get(gomock.Any(), FnGlobalSettings, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn string, out any) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	p := &probe{
+		ctx:       ctx,
+		target:    "127.0.0.1",
+		client:    metrics,
+		collector: &Collector{},
+		collectors: map[string]bool{
+			"global_settings": true,
+			"cm_state":        true,
+		},
+	}
+	reg := newTestRegistry(p.Collect)
+
+	want := strings.Join([]string{
+		`# HELP connect_box_scrape_collector_success Whether a collector succeeded.`,
+		`# TYPE connect_box_scrape_collector_success gauge`,
+		`connect_box_scrape_collector_success{collector="cm_state"} 0`,
+		`connect_box_scrape_collector_success{collector="global_settings"} 0`,
+		`# HELP connect_box_scrape_deadline_exceeded Whether the scrape was cut short by the deadline.`,
+		`# TYPE connect_box_scrape_deadline_exceeded gauge`,
+		`connect_box_scrape_deadline_exceeded 1`,
+		`# HELP connect_box_scrape_success Whether all data was successfully collected from the target.`,
+		`# TYPE connect_box_scrape_success gauge`,
+		`connect_box_scrape_success 0`,
+	}, "\n") + "\n"
+	err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"connect_box_scrape_collector_success",
+		"connect_box_scrape_deadline_exceeded",
+		"connect_box_scrape_success",
+	)
+	require.NoError(t, err)
+}
+
+func TestCollector_scrapeTarget_deadline(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	ctrl := gomock.NewController(t)
+
+	// Collecting uses up the deadline, logout still gets its own timeout
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Login(gomock.Any()).Return(nil)
+	metrics.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn string, out any) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	metrics.EXPECT().Logout(gomock.Any()).
+		DoAndReturn(func(ctx context.Context) error {
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+			return ctx.Err()
+		})
+
+	col := &Collector{
+		logoutTimeout: time.Minute,
+		modules: map[string]map[string]bool{
+			"cm_only": {"cm_state": true},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute+10*time.Millisecond)
+	defer cancel()
+
+	families, err := col.scrapeTarget(ctx, "127.0.0.1", "cm_only", metrics)
+	require.NoError(t, err)
+
+	want := strings.Join([]string{
+		`# HELP connect_box_scrape_deadline_exceeded Whether the scrape was cut short by the deadline.`,
+		`# TYPE connect_box_scrape_deadline_exceeded gauge`,
+		`connect_box_scrape_deadline_exceeded 1`,
+	}, "\n") + "\n"
+	err = testutil.GatherAndCompare(gathered(families), strings.NewReader(want),
+		"connect_box_scrape_deadline_exceeded")
+	require.NoError(t, err)
+}
+
+func TestCollector_collectContext(t *testing.T) {
+	col := &Collector{logoutTimeout: time.Minute}
+
+	t.Run("probe deadline", func(t *testing.T) {
+		probeCtx, probeCancel := context.WithTimeout(context.Background(), time.Hour)
+		defer probeCancel()
+		probeDeadline, _ := probeCtx.Deadline()
+
+		ctx, cancel := col.collectContext(probeCtx)
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.Equal(t, probeDeadline.Add(-time.Minute), deadline)
+	})
+
+	t.Run("no deadline", func(t *testing.T) {
+		ctx, cancel := col.collectContext(context.Background())
+		defer cancel()
+
+		_, ok := ctx.Deadline()
+		require.False(t, ok)
+	})
+}
+
+func TestCollector_logoutContext(t *testing.T) {
+	col := &Collector{logoutTimeout: time.Minute}
+
+	t.Run("logout timeout", func(t *testing.T) {
+		ctx, cancel := col.logoutContext(context.Background())
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	})
+
+	t.Run("expired probe", func(t *testing.T) {
+		probeCtx, probeCancel := context.WithTimeout(context.Background(), 0)
+		defer probeCancel()
+
+		ctx, cancel := col.logoutContext(probeCtx)
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+		require.NoError(t, ctx.Err())
+	})
+
+	t.Run("cancelled probe", func(t *testing.T) {
+		probeCtx, probeCancel := context.WithCancel(context.Background())
+		probeCancel()
+
+		ctx, cancel := col.logoutContext(probeCtx)
+		defer cancel()
+		require.NoError(t, ctx.Err())
 	})
 }
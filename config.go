@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"time"
 
@@ -13,6 +14,43 @@ type Config struct {
 	ListenAddr string        `yaml:"listen_addr"`
 	Timeout    time.Duration `yaml:"timeout"`
 	Targets    []Target      `yaml:"targets"`
+	// Modules are named sets of collectors, that can be selected
+	// per target or per probe request.
+	Modules map[string]Module `yaml:"modules"`
+
+	// TimeoutOffset is subtracted from the Prometheus scrape timeout
+	// to get the probe deadline, leaving time to send the response.
+	// Zero disables the offset, nil means the default.
+	TimeoutOffset *time.Duration `yaml:"timeout_offset"`
+	// LogoutTimeout is the max time of logout after a scrape, collecting
+	// stops when there is less time left before the probe deadline.
+	LogoutTimeout time.Duration `yaml:"logout_timeout"`
+	// QueueTimeout is the max time a probe waits for another probe
+	// of the same target to finish.
+	QueueTimeout time.Duration `yaml:"queue_timeout"`
+	// Coalesce makes simultaneous probes of the same target share
+	// a single result.
+	Coalesce bool `yaml:"coalesce"`
+	// LoginBackoff is the time login attempts are suppressed after
+	// a failure, it doubles after each consecutive failure up to
+	// LoginBackoffMax. Zero disables the backoff, nil means the default.
+	LoginBackoff    *time.Duration `yaml:"login_backoff"`
+	LoginBackoffMax time.Duration  `yaml:"login_backoff_max"`
+	// SessionMaxAge enables keeping targets logged in between scrapes,
+	// the session is renewed when it gets older.
+	SessionMaxAge time.Duration `yaml:"session_max_age"`
+	// Retry is the default retry policy for requests to targets.
+	Retry RetryPolicy `yaml:"retry"`
+
+	// AuthProfiles are named credentials for targets, that are not
+	// listed in the config, and are probed with the auth parameter.
+	AuthProfiles map[string]AuthProfile `yaml:"auth_profiles"`
+	// AllowedCIDRs are address ranges of targets, that can be probed
+	// with an auth profile.
+	AllowedCIDRs []string `yaml:"allowed_cidrs"`
+	// MaxDynamicTargets is the max number of cached clients of dynamic
+	// targets, the least recently used one is evicted.
+	MaxDynamicTargets int `yaml:"max_dynamic_targets"`
 }
 
 // Target is a single ConnectBox device.
@@ -20,6 +58,43 @@ type Target struct {
 	Addr     string `yaml:"addr"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// Timeout is the probe deadline, it overrides the Prometheus
+	// scrape timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// Module is the default module of the target, all collectors
+	// are enabled if it's empty.
+	Module string `yaml:"module"`
+
+	// PollInterval enables polling the target in background, so probe
+	// requests are served from the cache.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// StaleAfter is the max age of cached metrics before the probe
+	// reports failure.
+	StaleAfter time.Duration `yaml:"stale_after"`
+	// Retry overrides the default retry policy.
+	Retry *RetryPolicy `yaml:"retry"`
+}
+
+// AuthProfile is a set of credentials for dynamic targets.
+type AuthProfile struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// RetryPolicy defines how failed requests to a target are retried.
+type RetryPolicy struct {
+	// Attempts is the max number of attempts, including the first one.
+	Attempts int `yaml:"attempts"`
+	// Backoff is the delay before the first retry, it doubles
+	// after each retry.
+	Backoff time.Duration `yaml:"backoff"`
+	// RetryOn is a list of retryable kinds of errors: request, decode.
+	RetryOn []string `yaml:"retry_on"`
+}
+
+// Module is a set of enabled collectors.
+type Module struct {
+	Collectors []string `yaml:"collectors"`
 }
 
 // ReadConfig returns configuration populated from the config file.
@@ -40,6 +115,53 @@ func ReadConfig(file string) (Config, error) {
 	if conf.Timeout == 0 {
 		conf.Timeout = 30 * time.Second
 	}
+	if conf.TimeoutOffset == nil {
+		offset := 500 * time.Millisecond
+		conf.TimeoutOffset = &offset
+	}
+	if conf.LogoutTimeout == 0 {
+		conf.LogoutTimeout = time.Second
+	}
+	if conf.QueueTimeout == 0 {
+		conf.QueueTimeout = conf.Timeout
+	}
+	if conf.LoginBackoff == nil {
+		backoff := 30 * time.Second
+		conf.LoginBackoff = &backoff
+	}
+	if conf.LoginBackoffMax == 0 {
+		conf.LoginBackoffMax = 15 * time.Minute
+	}
+	if conf.MaxDynamicTargets == 0 {
+		conf.MaxDynamicTargets = 100
+	}
+	if err := conf.Retry.setDefaults(defaultRetryPolicy); err != nil {
+		return Config{}, fmt.Errorf("invalid retry policy: %w", err)
+	}
+	for name, m := range conf.Modules {
+		if len(m.Collectors) == 0 {
+			return Config{}, fmt.Errorf("found module %s without collectors", name)
+		}
+		for _, col := range m.Collectors {
+			if !isCollector(col) {
+				return Config{}, fmt.Errorf("found unknown collector %s in module %s", col, name)
+			}
+		}
+	}
+	for name, p := range conf.AuthProfiles {
+		if p.Username == "" {
+			p.Username = "NULL"
+		}
+		if p.Password == "" {
+			return Config{}, fmt.Errorf("found auth profile %s with empty password", name)
+		}
+		conf.AuthProfiles[name] = p
+	}
+	for _, s := range conf.AllowedCIDRs {
+		if _, err := netip.ParsePrefix(s); err != nil {
+			return Config{}, fmt.Errorf("found invalid allowed CIDR %s", s)
+		}
+	}
 	for i := range conf.Targets {
 		if conf.Targets[i].Addr == "" {
 			return Config{}, fmt.Errorf("found target with empty address")
@@ -50,7 +172,49 @@ func ReadConfig(file string) (Config, error) {
 		if conf.Targets[i].Password == "" {
 			return Config{}, fmt.Errorf("found target with empty password")
 		}
+		if _, ok := conf.Modules[conf.Targets[i].Module]; conf.Targets[i].Module != "" && !ok {
+			return Config{}, fmt.Errorf("found target with unknown module %s", conf.Targets[i].Module)
+		}
+		if conf.Targets[i].Retry != nil {
+			if err := conf.Targets[i].Retry.setDefaults(conf.Retry); err != nil {
+				return Config{}, fmt.Errorf("invalid retry policy of target %s: %w",
+					conf.Targets[i].Addr, err)
+			}
+		}
+		if conf.Targets[i].PollInterval > 0 && conf.Targets[i].StaleAfter == 0 {
+			conf.Targets[i].StaleAfter = 3 * conf.Targets[i].PollInterval
+		}
 	}
 
 	return conf, nil
 }
+
+// defaultRetryPolicy disables retries.
+var defaultRetryPolicy = RetryPolicy{
+	Attempts: 1,
+	Backoff:  time.Second,
+	RetryOn:  []string{RetryOnRequest},
+}
+
+// setDefaults takes unset values of the policy from the defaults, and
+// validates it.
+func (p *RetryPolicy) setDefaults(defaults RetryPolicy) error {
+	if p.Attempts < 0 {
+		return fmt.Errorf("negative attempts")
+	}
+	if p.Attempts == 0 {
+		p.Attempts = defaults.Attempts
+	}
+	if p.Backoff == 0 {
+		p.Backoff = defaults.Backoff
+	}
+	if p.RetryOn == nil {
+		p.RetryOn = defaults.RetryOn
+	}
+	for _, k := range p.RetryOn {
+		if k != RetryOnRequest && k != RetryOnDecode {
+			return fmt.Errorf("unknown error kind %s", k)
+		}
+	}
+	return nil
+}
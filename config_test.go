@@ -31,8 +31,19 @@ func TestReadConfig(t *testing.T) {
 		require.NoError(t, err)
 
 		want := Config{
-			ListenAddr: "0.0.0.0:9119",
-			Timeout:    10 * time.Second,
+			ListenAddr:        "0.0.0.0:9119",
+			Timeout:           10 * time.Second,
+			TimeoutOffset:     durationPtr(500 * time.Millisecond),
+			LogoutTimeout:     time.Second,
+			QueueTimeout:      10 * time.Second,
+			LoginBackoff:      durationPtr(30 * time.Second),
+			LoginBackoffMax:   15 * time.Minute,
+			MaxDynamicTargets: 100,
+			Retry: RetryPolicy{
+				Attempts: 1,
+				Backoff:  time.Second,
+				RetryOn:  []string{RetryOnRequest},
+			},
 			Targets: []Target{{
 				Addr:     "192.168.178.1",
 				Username: "NULL",
@@ -62,8 +73,19 @@ func TestReadConfig(t *testing.T) {
 		require.NoError(t, err)
 
 		want := Config{
-			ListenAddr: "0.0.0.0:9119",
-			Timeout:    30 * time.Second,
+			ListenAddr:        "0.0.0.0:9119",
+			Timeout:           30 * time.Second,
+			TimeoutOffset:     durationPtr(500 * time.Millisecond),
+			LogoutTimeout:     time.Second,
+			QueueTimeout:      30 * time.Second,
+			LoginBackoff:      durationPtr(30 * time.Second),
+			LoginBackoffMax:   15 * time.Minute,
+			MaxDynamicTargets: 100,
+			Retry: RetryPolicy{
+				Attempts: 1,
+				Backoff:  time.Second,
+				RetryOn:  []string{RetryOnRequest},
+			},
 			Targets: []Target{{
 				Addr:     "192.168.178.1",
 				Username: "NULL",
@@ -73,6 +95,29 @@ func TestReadConfig(t *testing.T) {
 		require.Equal(t, want, conf)
 	})
 
+	t.Run("disabled defaults", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"timeout_offset: 0s\n" +
+				"login_backoff: 0s\n" +
+				"targets:\n" +
+				"  - addr: 192.168.178.1\n" +
+				"    password: password",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		conf, err := ReadConfig(file.Name())
+		require.NoError(t, err)
+		require.Equal(t, durationPtr(0), conf.TimeoutOffset)
+		require.Equal(t, durationPtr(0), conf.LoginBackoff)
+	})
+
 	t.Run("empty target address", func(t *testing.T) {
 		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
 		require.NoError(t, err)
@@ -113,8 +158,19 @@ func TestReadConfig(t *testing.T) {
 		require.NoError(t, err)
 
 		want := Config{
-			ListenAddr: "0.0.0.0:9119",
-			Timeout:    30 * time.Second,
+			ListenAddr:        "0.0.0.0:9119",
+			Timeout:           30 * time.Second,
+			TimeoutOffset:     durationPtr(500 * time.Millisecond),
+			LogoutTimeout:     time.Second,
+			QueueTimeout:      30 * time.Second,
+			LoginBackoff:      durationPtr(30 * time.Second),
+			LoginBackoffMax:   15 * time.Minute,
+			MaxDynamicTargets: 100,
+			Retry: RetryPolicy{
+				Attempts: 1,
+				Backoff:  time.Second,
+				RetryOn:  []string{RetryOnRequest},
+			},
 			Targets: []Target{{
 				Addr:     "192.168.178.1",
 				Username: "NULL",
@@ -124,6 +180,50 @@ func TestReadConfig(t *testing.T) {
 		require.Equal(t, want, conf)
 	})
 
+	t.Run("default stale after", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"targets:\n" +
+				"  - addr: 192.168.178.1\n" +
+				"    password: password\n" +
+				"    poll_interval: 1m",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		conf, err := ReadConfig(file.Name())
+		require.NoError(t, err)
+
+		want := Config{
+			ListenAddr:        "0.0.0.0:9119",
+			Timeout:           30 * time.Second,
+			TimeoutOffset:     durationPtr(500 * time.Millisecond),
+			LogoutTimeout:     time.Second,
+			QueueTimeout:      30 * time.Second,
+			LoginBackoff:      durationPtr(30 * time.Second),
+			LoginBackoffMax:   15 * time.Minute,
+			MaxDynamicTargets: 100,
+			Retry: RetryPolicy{
+				Attempts: 1,
+				Backoff:  time.Second,
+				RetryOn:  []string{RetryOnRequest},
+			},
+			Targets: []Target{{
+				Addr:         "192.168.178.1",
+				Username:     "NULL",
+				Password:     "password",
+				PollInterval: time.Minute,
+				StaleAfter:   3 * time.Minute,
+			}},
+		}
+		require.Equal(t, want, conf)
+	})
+
 	t.Run("empty target password", func(t *testing.T) {
 		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
 		require.NoError(t, err)
@@ -144,6 +244,183 @@ func TestReadConfig(t *testing.T) {
 		require.ErrorContains(t, err, "found target with empty password")
 	})
 
+	t.Run("modules", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"modules:\n" +
+				"  rf_only:\n" +
+				"    collectors: [downstream_table, upstream_table]\n" +
+				"targets:\n" +
+				"  - addr: 192.168.178.1\n" +
+				"    password: password\n" +
+				"    module: rf_only",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		conf, err := ReadConfig(file.Name())
+		require.NoError(t, err)
+
+		want := map[string]Module{
+			"rf_only": {Collectors: []string{"downstream_table", "upstream_table"}},
+		}
+		require.Equal(t, want, conf.Modules)
+		require.Equal(t, "rf_only", conf.Targets[0].Module)
+	})
+
+	t.Run("unknown collector", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"modules:\n" +
+				"  rf_only:\n" +
+				"    collectors: [downstream]\n",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		_, err = ReadConfig(file.Name())
+		require.ErrorContains(t, err, "found unknown collector downstream in module rf_only")
+	})
+
+	t.Run("unknown target module", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"targets:\n" +
+				"  - addr: 192.168.178.1\n" +
+				"    password: password\n" +
+				"    module: rf_only",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		_, err = ReadConfig(file.Name())
+		require.ErrorContains(t, err, "found target with unknown module rf_only")
+	})
+
+	t.Run("target retry policy", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"retry:\n" +
+				"  backoff: 2s\n" +
+				"  retry_on: [request, decode]\n" +
+				"targets:\n" +
+				"  - addr: 192.168.178.1\n" +
+				"    password: password\n" +
+				"    retry:\n" +
+				"      attempts: 3",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		// Unset values are taken from the global policy
+		conf, err := ReadConfig(file.Name())
+		require.NoError(t, err)
+		require.Equal(t, &RetryPolicy{
+			Attempts: 3,
+			Backoff:  2 * time.Second,
+			RetryOn:  []string{RetryOnRequest, RetryOnDecode},
+		}, conf.Targets[0].Retry)
+	})
+
+	t.Run("unknown retry error kind", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"retry:\n" +
+				"  retry_on: [timeout]\n" +
+				"targets:\n" +
+				"  - addr: 192.168.178.1\n" +
+				"    password: password",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		_, err = ReadConfig(file.Name())
+		require.ErrorContains(t, err, "invalid retry policy: unknown error kind timeout")
+	})
+
+	t.Run("auth profiles", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"auth_profiles:\n" +
+				"  default:\n" +
+				"    password: password\n" +
+				"allowed_cidrs: [10.0.0.0/8]",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		conf, err := ReadConfig(file.Name())
+		require.NoError(t, err)
+		require.Equal(t, map[string]AuthProfile{
+			"default": {Username: "NULL", Password: "password"},
+		}, conf.AuthProfiles)
+		require.Equal(t, []string{"10.0.0.0/8"}, conf.AllowedCIDRs)
+	})
+
+	t.Run("auth profile without password", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString(
+			"auth_profiles:\n" +
+				"  default:\n" +
+				"    username: admin",
+		)
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		_, err = ReadConfig(file.Name())
+		require.ErrorContains(t, err, "found auth profile default with empty password")
+	})
+
+	t.Run("invalid allowed CIDR", func(t *testing.T) {
+		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+		require.NoError(t, err)
+		defer os.Remove(file.Name())
+
+		_, err = file.WriteString("allowed_cidrs: [10.0.0.0]")
+		require.NoError(t, err)
+
+		err = file.Close()
+		require.NoError(t, err)
+
+		_, err = ReadConfig(file.Name())
+		require.ErrorContains(t, err, "found invalid allowed CIDR 10.0.0.0")
+	})
+
 	t.Run("invalid yaml", func(t *testing.T) {
 		file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
 		require.NoError(t, err)
@@ -164,3 +441,7 @@ func TestReadConfig(t *testing.T) {
 		require.ErrorContains(t, err, "no such file or directory")
 	})
 }
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
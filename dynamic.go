@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/tetafro/connectbox"
+)
+
+var (
+	errUnknownTarget = errors.New("unknown target")
+	errUnknownAuth   = errors.New("unknown auth profile")
+	errNotAllowed    = errors.New("target is not allowed")
+)
+
+// dynamicTarget is a key of the dynamic client cache.
+type dynamicTarget struct {
+	addr string
+	auth string
+}
+
+// dynamicClient is a cached client of a dynamic target.
+type dynamicClient struct {
+	client ConnectBox
+	used   time.Time
+}
+
+// newClient creates a ConnectBox client.
+func newClient(addr, username, password string) (ConnectBox, error) {
+	return connectbox.NewClient(addr, username, password) //nolint:wrapcheck
+}
+
+// client returns the target name and the client of the target. Targets
+// from the config are always used as is. Other targets must be IP addresses
+// without port, they are created on the fly with credentials from the auth
+// profile, if their address is in the allowlist, and cached for subsequent
+// probes. The least recently used client is evicted when the cache is full.
+func (c *Collector) client(target, auth string) (string, ConnectBox, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.targets[target]; ok {
+		return target, client, nil
+	}
+	if auth == "" {
+		return "", nil, errUnknownTarget
+	}
+	profile, ok := c.authProfiles[auth]
+	if !ok {
+		return "", nil, errUnknownAuth
+	}
+	addr, err := netip.ParseAddr(target)
+	if err != nil || addr.Zone() != "" {
+		return "", nil, errNotAllowed
+	}
+	addr = addr.Unmap()
+	target = addr.String()
+	if client, ok := c.targets[target]; ok {
+		return target, client, nil
+	}
+	if !c.allowed(addr) {
+		return "", nil, errNotAllowed
+	}
+
+	key := dynamicTarget{addr: target, auth: auth}
+	if d, ok := c.dynamic[key]; ok {
+		d.used = time.Now()
+		return target, d.client, nil
+	}
+	// IPv6 addresses must be in brackets to be used as a host in URLs
+	host := target
+	if addr.Is6() {
+		host = "[" + target + "]"
+	}
+	client, err := c.newClient(host, profile.Username, profile.Password)
+	if err != nil {
+		return "", nil, fmt.Errorf("create client: %w", err)
+	}
+	if c.dynamic == nil {
+		c.dynamic = map[dynamicTarget]*dynamicClient{}
+	}
+	if c.maxDynamic > 0 && len(c.dynamic) >= c.maxDynamic {
+		c.evict()
+	}
+	d := &dynamicClient{client: c.wrap(target, client, c.retry), used: time.Now()}
+	c.dynamic[key] = d
+	return target, d.client, nil
+}
+
+// allowed checks if the address is in the allowlist. It must be called
+// with the mutex locked.
+func (c *Collector) allowed(addr netip.Addr) bool {
+	for _, p := range c.allowedCIDRs {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// evict removes the least recently used dynamic client, and logs out
+// from it in background. It must be called with the mutex locked.
+func (c *Collector) evict() {
+	var (
+		oldest dynamicTarget
+		used   time.Time
+	)
+	for key, d := range c.dynamic {
+		if used.IsZero() || d.used.Before(used) {
+			oldest, used = key, d.used
+		}
+	}
+	d := c.dynamic[oldest]
+	delete(c.dynamic, oldest)
+	c.forget(oldest.addr)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		defer cancel()
+		c.closeSessions(ctx, map[ConnectBox]string{d.client: oldest.addr})
+	}()
+}
+
+// forget removes the event log and exporter metrics of the target, unless
+// the target is still in use. It must be called with the mutex locked.
+func (c *Collector) forget(target string) {
+	if _, ok := c.targets[target]; ok {
+		return
+	}
+	for key := range c.dynamic {
+		if key.addr == target {
+			return
+		}
+	}
+	delete(c.events, target)
+	deleteTargetMetrics(target)
+}
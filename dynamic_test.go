@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollector_client(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	static := NewMockConnectBox(ctrl)
+
+	var created []string
+	col := &Collector{
+		targets:      map[string]ConnectBox{"192.168.178.1": static},
+		authProfiles: map[string]AuthProfile{"default": {Username: "NULL", Password: "password"}},
+		allowedCIDRs: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.168.178.0/24"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		},
+		newClient: func(addr, username, password string) (ConnectBox, error) {
+			created = append(created, addr)
+			if addr == "10.0.0.2" {
+				return nil, errors.New("fail")
+			}
+			return NewMockConnectBox(ctrl), nil
+		},
+	}
+
+	t.Run("static target", func(t *testing.T) {
+		target, client, err := col.client("192.168.178.1", "default")
+		require.NoError(t, err)
+		require.Equal(t, "192.168.178.1", target)
+		require.Equal(t, static, client)
+	})
+
+	t.Run("static target with another address form", func(t *testing.T) {
+		target, client, err := col.client("::ffff:192.168.178.1", "default")
+		require.NoError(t, err)
+		require.Equal(t, "192.168.178.1", target)
+		require.Equal(t, static, client)
+	})
+
+	t.Run("unknown target", func(t *testing.T) {
+		_, _, err := col.client("10.0.0.1", "")
+		require.ErrorIs(t, err, errUnknownTarget)
+	})
+
+	t.Run("unknown auth profile", func(t *testing.T) {
+		_, _, err := col.client("10.0.0.1", "other")
+		require.ErrorIs(t, err, errUnknownAuth)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		for _, target := range []string{"192.168.0.1", "example.com", "10.0.0.1:80"} {
+			_, _, err := col.client(target, "default")
+			require.ErrorIs(t, err, errNotAllowed, target)
+		}
+	})
+
+	t.Run("failed to create client", func(t *testing.T) {
+		_, _, err := col.client("10.0.0.2", "default")
+		require.EqualError(t, err, "create client: fail")
+	})
+
+	t.Run("cached client", func(t *testing.T) {
+		created = nil
+
+		_, first, err := col.client("10.0.0.1", "default")
+		require.NoError(t, err)
+		require.IsType(t, &instrumentedClient{}, first)
+
+		target, second, err := col.client("::ffff:10.0.0.1", "default")
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.1", target)
+		require.Same(t, first, second)
+
+		require.Equal(t, []string{"10.0.0.1"}, created)
+	})
+
+	t.Run("ipv6 target", func(t *testing.T) {
+		created = nil
+
+		target, _, err := col.client("2001:db8::1", "default")
+		require.NoError(t, err)
+		require.Equal(t, "2001:db8::1", target)
+		require.Equal(t, []string{"[2001:db8::1]"}, created)
+	})
+}
+
+func TestCollector_client_evict(t *testing.T) {
+	probesCounter.Reset()
+
+	ctrl := gomock.NewController(t)
+	col := &Collector{
+		authProfiles: map[string]AuthProfile{"default": {Username: "NULL", Password: "password"}},
+		allowedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		newClient: func(addr, username, password string) (ConnectBox, error) {
+			return NewMockConnectBox(ctrl), nil
+		},
+		maxDynamic: 2,
+	}
+
+	_, first, err := col.client("10.0.0.1", "default")
+	require.NoError(t, err)
+	countProbe("10.0.0.1", http.StatusOK)
+	_, _, err = col.client("10.0.0.2", "default")
+	require.NoError(t, err)
+
+	// Use the first client, so the second one is evicted
+	_, _, err = col.client("10.0.0.1", "default")
+	require.NoError(t, err)
+	_, _, err = col.client("10.0.0.3", "default")
+	require.NoError(t, err)
+
+	require.Len(t, col.dynamic, 2)
+	require.Contains(t, col.dynamic, dynamicTarget{addr: "10.0.0.1", auth: "default"})
+	require.Contains(t, col.dynamic, dynamicTarget{addr: "10.0.0.3", auth: "default"})
+
+	// Metrics of the evicted target are removed
+	_, _, err = col.client("10.0.0.4", "default")
+	require.NoError(t, err)
+	require.Equal(t, 0, testutil.CollectAndCount(probesCounter))
+
+	_, again, err := col.client("10.0.0.1", "default")
+	require.NoError(t, err)
+	require.NotSame(t, first, again)
+}
+
+func TestCollector_ServeHTTP_dynamic(t *testing.T) {
+	col := &Collector{
+		authProfiles: map[string]AuthProfile{"default": {Username: "NULL", Password: "password"}},
+		allowedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	testCases := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{
+			name:   "unknown target",
+			url:    "/probe?target=10.0.0.1",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown auth profile",
+			url:    "/probe?target=10.0.0.1&auth=other",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "not allowed",
+			url:    "/probe?target=192.168.0.1&auth=default",
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "port",
+			url:    "/probe?target=10.0.0.1:80&auth=default",
+			status: http.StatusForbidden,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			col.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+// eventLog keeps track of the router event log entries between scrapes,
+// so each entry is counted only once.
+type eventLog struct {
+	seen   map[EventLogTableEvent]struct{}
+	counts map[eventKey]int
+	last   int64
+}
+
+// eventKey identifies a group of events for counting.
+type eventKey struct {
+	priority string
+	id       string
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{
+		seen:   map[EventLogTableEvent]struct{}{},
+		counts: map[eventKey]int{},
+	}
+}
+
+// update counts entries that were not present in the previous snapshot
+// of the log. Entries that rotated out of the router's log are forgotten,
+// because they can't appear again.
+func (l *eventLog) update(events []EventLogTableEvent) {
+	seen := make(map[EventLogTableEvent]struct{}, len(events))
+	for _, e := range events {
+		if _, ok := seen[e]; ok {
+			continue
+		}
+		seen[e] = struct{}{}
+		if e.Timestamp > l.last {
+			l.last = e.Timestamp
+		}
+		if _, ok := l.seen[e]; !ok {
+			l.counts[eventKey{priority: e.Priority, id: e.ID()}]++
+		}
+	}
+	l.seen = seen
+}
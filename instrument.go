@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Exporter metrics, exposed on /metrics.
+var (
+	loginAttemptsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_box_exporter_login_attempts_total",
+		Help: "Login attempts.",
+	}, []string{"target"})
+	loginFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_box_exporter_login_failures_total",
+		Help: "Failed login attempts.",
+	}, []string{"target"})
+	logoutFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_box_exporter_logout_failures_total",
+		Help: "Failed logout attempts.",
+	}, []string{"target"})
+	requestDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "connect_box_exporter_request_duration_seconds",
+		Help:    "Duration of requests to the router API.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"target", "fn"})
+	decodeErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_box_exporter_decode_errors_total",
+		Help: "Router API responses that could not be decoded.",
+	}, []string{"target", "fn"})
+	probesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "connect_box_exporter_probes_total",
+		Help: "Probe requests by HTTP response status.",
+	}, []string{"target", "status"})
+)
+
+// instrumentedClient is a ConnectBox client, that records exporter
+// metrics for every call to the underlying client.
+type instrumentedClient struct {
+	target string
+	client ConnectBox
+}
+
+func instrument(target string, client ConnectBox) *instrumentedClient {
+	return &instrumentedClient{target: target, client: client}
+}
+
+// Login logs in to the router.
+func (c *instrumentedClient) Login(ctx context.Context) error {
+	loginAttemptsCounter.WithLabelValues(c.target).Inc()
+	err := c.client.Login(ctx)
+	if err != nil {
+		loginFailuresCounter.WithLabelValues(c.target).Inc()
+	}
+	return err //nolint:wrapcheck
+}
+
+// Logout logs out from the router.
+func (c *instrumentedClient) Logout(ctx context.Context) error {
+	err := c.client.Logout(ctx)
+	if err != nil {
+		logoutFailuresCounter.WithLabelValues(c.target).Inc()
+	}
+	return err //nolint:wrapcheck
+}
+
+// Get gets data from the router.
+func (c *instrumentedClient) Get(ctx context.Context, fn string, out any) error {
+	start := time.Now()
+	err := c.client.Get(ctx, fn, out)
+	requestDurationHistogram.WithLabelValues(c.target, fn).
+		Observe(time.Since(start).Seconds())
+	if isDecodeError(err) {
+		decodeErrorsCounter.WithLabelValues(c.target, fn).Inc()
+	}
+	return err //nolint:wrapcheck
+}
+
+// deleteTargetMetrics removes exporter metrics of the target.
+func deleteTargetMetrics(target string) {
+	labels := prometheus.Labels{"target": target}
+	loginAttemptsCounter.DeletePartialMatch(labels)
+	loginFailuresCounter.DeletePartialMatch(labels)
+	logoutFailuresCounter.DeletePartialMatch(labels)
+	requestDurationHistogram.DeletePartialMatch(labels)
+	decodeErrorsCounter.DeletePartialMatch(labels)
+	probesCounter.DeletePartialMatch(labels)
+	retriesCounter.DeletePartialMatch(labels)
+	loginBackoffGauge.DeletePartialMatch(labels)
+}
+
+// isDecodeError checks if the error happened while decoding the response.
+// The client library doesn't export typed errors, so the check relies on
+// the error message.
+func isDecodeError(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "unmarshal response:")
+}
+
+// statusRecorder is an http.ResponseWriter, that remembers
+// the response status.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader saves the status and writes it to the response.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// countProbe records a probe request with the given response status.
+func countProbe(target string, status int) {
+	probesCounter.WithLabelValues(target, strconv.Itoa(status)).Inc()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestInstrumentedClient(t *testing.T) {
+	loginAttemptsCounter.Reset()
+	loginFailuresCounter.Reset()
+	logoutFailuresCounter.Reset()
+	decodeErrorsCounter.Reset()
+
+	t.Run("success", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		client.EXPECT().Login(gomock.Any()).Return(nil)
+		client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).Return(nil)
+		client.EXPECT().Logout(gomock.Any()).Return(nil)
+
+		c := instrument("success", client)
+		require.NoError(t, c.Login(context.Background()))
+		require.NoError(t, c.Get(context.Background(), FnCMState, &CMState{}))
+		require.NoError(t, c.Logout(context.Background()))
+
+		require.Equal(t, 1.0, testutil.ToFloat64(
+			loginAttemptsCounter.WithLabelValues("success")))
+		require.Equal(t, 0.0, testutil.ToFloat64(
+			loginFailuresCounter.WithLabelValues("success")))
+		require.Equal(t, 0.0, testutil.ToFloat64(
+			logoutFailuresCounter.WithLabelValues("success")))
+		require.Equal(t, 0.0, testutil.ToFloat64(
+			decodeErrorsCounter.WithLabelValues("success", FnCMState)))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		client.EXPECT().Login(gomock.Any()).Return(errors.New("fail"))
+		client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+			Return(fmt.Errorf("unmarshal response: %w", errors.New("fail")))
+		client.EXPECT().Logout(gomock.Any()).Return(errors.New("fail"))
+
+		c := instrument("failure", client)
+		require.Error(t, c.Login(context.Background()))
+		require.Error(t, c.Get(context.Background(), FnCMState, &CMState{}))
+		require.Error(t, c.Logout(context.Background()))
+
+		require.Equal(t, 1.0, testutil.ToFloat64(
+			loginAttemptsCounter.WithLabelValues("failure")))
+		require.Equal(t, 1.0, testutil.ToFloat64(
+			loginFailuresCounter.WithLabelValues("failure")))
+		require.Equal(t, 1.0, testutil.ToFloat64(
+			logoutFailuresCounter.WithLabelValues("failure")))
+		require.Equal(t, 1.0, testutil.ToFloat64(
+			decodeErrorsCounter.WithLabelValues("failure", FnCMState)))
+	})
+}
+
+func TestIsDecodeError(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			want: false,
+		},
+		{
+			name: "decode error",
+			err:  fmt.Errorf("unmarshal response: %w", errors.New("fail")),
+			want: true,
+		},
+		{
+			name: "request error",
+			err:  fmt.Errorf("get response: %w", errors.New("fail")),
+			want: false,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isDecodeError(tt.err))
+		})
+	}
+}
+
+func TestCountProbe(t *testing.T) {
+	probesCounter.Reset()
+
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	rec.WriteHeader(http.StatusInternalServerError)
+	countProbe("count-probe", rec.status)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(
+		probesCounter.WithLabelValues("count-probe", "500")))
+}
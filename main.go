@@ -14,7 +14,6 @@ import (
 	"syscall"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/tetafro/connectbox"
 )
 
 func main() {
@@ -26,6 +25,7 @@ func main() {
 	defer cancel()
 
 	configFile := flag.String("config", "./config.yml", "path to config file")
+	enableLifecycle := flag.Bool("web.enable-lifecycle", false, "enable config reload via HTTP")
 	flag.Parse()
 
 	conf, err := ReadConfig(*configFile)
@@ -34,26 +34,34 @@ func main() {
 	}
 
 	// Create a client for each target
-	targets := map[string]ConnectBox{}
-	for _, t := range conf.Targets {
-		client, err := connectbox.NewClient(
-			t.Addr,
-			t.Username,
-			t.Password,
-		)
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("Failed to init ConnectBox client: %v", err)
-		}
-		targets[t.Addr] = client
+	targets, err := newTargets(conf)
+	if err != nil {
+		log.Fatalf("Failed to init ConnectBox client: %v", err)
 	}
 
 	// Init prometheus metrics collector
-	collector := NewCollector(conf.Timeout, targets)
+	collector := NewCollector(conf, targets)
+	reloader := newReloader(ctx, *configFile, collector)
+	reloader.Start(conf)
+
+	// Reload config on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloader.Reload(); err != nil {
+				log.Printf("Failed to reload config: %v", err)
+			}
+		}
+	}()
 
 	// Create HTTP server
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.Handle("/probe", collector)
+	if *enableLifecycle {
+		mux.Handle("/-/reload", reloader)
+	}
 	//nolint:gosec
 	srv := http.Server{
 		Addr:    conf.ListenAddr,
@@ -78,5 +86,19 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	}
+	collector.Close(ctx)
 	fmt.Println("Shutdown gracefully")
 }
+
+// newTargets creates a client for each target from the config.
+func newTargets(conf Config) (map[string]ConnectBox, error) {
+	targets := make(map[string]ConnectBox, len(conf.Targets))
+	for _, t := range conf.Targets {
+		client, err := newClient(t.Addr, t.Username, t.Password)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", t.Addr, err)
+		}
+		targets[t.Addr] = client
+	}
+	return targets, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var scrapeAgeDesc = newDesc(
+	"connect_box_scrape_age_seconds",
+	"Time since the cached metrics were collected from the target.",
+)
+
+// poller keeps the latest metrics of a target, that is polled in background.
+type poller struct {
+	staleAfter time.Duration
+
+	mu       sync.Mutex
+	families []*dto.MetricFamily
+	updated  time.Time
+}
+
+// Poll starts polling the target in background with the given interval,
+// using the default module of the target. Probe requests for the target
+// are served from the cache until the cached metrics are older than
+// staleAfter. Polling stops when the context is cancelled. Cached metrics
+// of the previous poller of the target are kept.
+func (c *Collector) Poll(
+	ctx context.Context,
+	target string,
+	interval time.Duration,
+	staleAfter time.Duration,
+) {
+	c.mu.Lock()
+	client, ok := c.targets[target]
+	if !ok {
+		c.mu.Unlock()
+		log.Printf("Unknown target for polling: %s", target)
+		return
+	}
+	p := &poller{staleAfter: staleAfter}
+	if prev, ok := c.pollers[target]; ok {
+		prev.mu.Lock()
+		p.families, p.updated = prev.families, prev.updated
+		prev.mu.Unlock()
+	}
+	if c.pollers == nil {
+		c.pollers = map[string]*poller{}
+	}
+	c.pollers[target] = p
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			c.poll(ctx, p, target, client)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// poll scrapes the target once and updates the cache.
+func (c *Collector) poll(ctx context.Context, p *poller, target string, client ConnectBox) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	families, err := c.scrape(ctx, target, c.targetConf(target).Module, client)
+	if err != nil {
+		log.Printf("Failed to poll %s: %v", target, err)
+		return
+	}
+	p.update(families, time.Now())
+}
+
+// poller returns the poller of the target, or nil if the target
+// is not polled.
+func (c *Collector) poller(target string) *poller {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pollers[target]
+}
+
+func (p *poller) update(families []*dto.MetricFamily, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.families = families
+	p.updated = now
+}
+
+// snapshot returns the cached metrics with their age. If there are no
+// cached metrics yet, or they are stale, the scrape is reported as failed.
+func (p *poller) snapshot(now time.Time) prometheus.Gatherer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := &cacheStatus{
+		cached: !p.updated.IsZero(),
+		age:    now.Sub(p.updated),
+	}
+	status.stale = !status.cached || status.age > p.staleAfter
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(status)
+	if status.stale {
+		return reg
+	}
+	return prometheus.Gatherers{gathered(p.families), reg}
+}
+
+// cacheStatus reports the state of the cached metrics.
+type cacheStatus struct {
+	cached bool
+	stale  bool
+	age    time.Duration
+}
+
+// Describe sends descriptors of the cache status metrics.
+func (s *cacheStatus) Describe(ch chan<- *prometheus.Desc) {
+	ch <- scrapeSuccessDesc
+	ch <- scrapeAgeDesc
+}
+
+// Collect sends the cache status metrics. Stale metrics are reported
+// as a failed scrape, and the age is only known if there are cached
+// metrics.
+func (s *cacheStatus) Collect(ch chan<- prometheus.Metric) {
+	if s.stale {
+		gauge(ch, scrapeSuccessDesc, 0)
+	}
+	if s.cached {
+		gauge(ch, scrapeAgeDesc, s.age.Seconds())
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollector_Poll(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	ctrl := gomock.NewController(t)
+
+	// Only one scrape is expected, probe requests are served from the cache
+	metrics := NewMockConnectBox(ctrl)
+	metrics.EXPECT().Login(gomock.Any()).Return(nil)
+	metrics.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).Times(defaultCollectors())
+	metrics.EXPECT().Logout(gomock.Any()).Return(nil)
+
+	col := &Collector{
+		timeout: time.Second,
+		targets: map[string]ConnectBox{
+			"127.0.0.1": metrics,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	col.Poll(ctx, "127.0.0.1", time.Hour, time.Hour)
+
+	p := col.poller("127.0.0.1")
+	require.NotNil(t, p)
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return !p.updated.IsZero()
+	}, time.Second, 10*time.Millisecond)
+
+	req, err := http.NewRequest(http.MethodGet, "/probe?target=127.0.0.1", nil)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	col.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "\nconnect_box_scrape_success 1\n")
+	require.Contains(t, rec.Body.String(), "\nconnect_box_scrape_age_seconds ")
+}
+
+func TestPoller_snapshot(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	families, err := newTestRegistry(func(ch chan<- prometheus.Metric) {
+		gauge(ch, cmSystemUptimeDesc, 100)
+	}).Gather()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		updated time.Time
+		want    string
+	}{
+		{
+			name:    "no data",
+			updated: time.Time{},
+			want: strings.Join([]string{
+				`# HELP connect_box_scrape_success Whether all data was successfully collected from the target.`,
+				`# TYPE connect_box_scrape_success gauge`,
+				`connect_box_scrape_success 0`,
+			}, "\n") + "\n",
+		},
+		{
+			name:    "fresh data",
+			updated: now.Add(-10 * time.Second),
+			want: strings.Join([]string{
+				`# HELP connect_box_cm_system_uptime System uptime.`,
+				`# TYPE connect_box_cm_system_uptime gauge`,
+				`connect_box_cm_system_uptime 100`,
+				`# HELP connect_box_scrape_age_seconds Time since the cached metrics were collected from the target.`,
+				`# TYPE connect_box_scrape_age_seconds gauge`,
+				`connect_box_scrape_age_seconds 10`,
+			}, "\n") + "\n",
+		},
+		{
+			name:    "stale data",
+			updated: now.Add(-2 * time.Minute),
+			want: strings.Join([]string{
+				`# HELP connect_box_scrape_age_seconds Time since the cached metrics were collected from the target.`,
+				`# TYPE connect_box_scrape_age_seconds gauge`,
+				`connect_box_scrape_age_seconds 120`,
+				`# HELP connect_box_scrape_success Whether all data was successfully collected from the target.`,
+				`# TYPE connect_box_scrape_success gauge`,
+				`connect_box_scrape_success 0`,
+			}, "\n") + "\n",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &poller{staleAfter: time.Minute}
+			if !tt.updated.IsZero() {
+				p.update(families, tt.updated)
+			}
+
+			err := testutil.GatherAndCompare(p.snapshot(now), strings.NewReader(tt.want))
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// errQueueTimeout is returned when a scrape waits for another scrape
+// of the same target for too long.
+var errQueueTimeout = errors.New("queue timeout")
+
+// queue serializes scrapes of a single router, because it doesn't support
+// concurrent sessions.
+type queue struct {
+	sem chan struct{}
+	// users is the number of scrapes that use the queue, the queue
+	// is removed when it's not used
+	users int
+
+	// call is the scrape in progress, it is shared between simultaneous
+	// requests when coalescing is enabled
+	call *scrapeCall
+}
+
+// scrapeCall is a result of a single scrape.
+type scrapeCall struct {
+	module  string
+	done    chan struct{}
+	metrics []*dto.MetricFamily
+	err     error
+}
+
+func newQueue() *queue {
+	return &queue{sem: make(chan struct{}, 1)}
+}
+
+// scrape logs in to the target, collects metrics of the module, and logs
+// out. Scrapes of the same target run one at a time, when coalescing is
+// enabled, requests that come during a scrape of the same module share
+// its result.
+func (c *Collector) scrape(
+	ctx context.Context,
+	target string,
+	module string,
+	client ConnectBox,
+) ([]*dto.MetricFamily, error) {
+	q := c.acquireQueue(target)
+	defer c.releaseQueue(target, q)
+
+	// Zero timeout means waiting until the context is cancelled
+	var timeout <-chan time.Time
+	if c.queueTimeout > 0 {
+		timer := time.NewTimer(c.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	if c.coalesce {
+		c.mu.Lock()
+		call := q.call
+		c.mu.Unlock()
+		if call != nil && call.module == module {
+			select {
+			case <-call.done:
+				return call.metrics, call.err
+			case <-timeout:
+				return nil, errQueueTimeout
+			case <-ctx.Done():
+				return nil, fmt.Errorf("wait for scrape: %w", ctx.Err())
+			}
+		}
+	}
+
+	select {
+	case q.sem <- struct{}{}:
+	case <-timeout:
+		return nil, errQueueTimeout
+	case <-ctx.Done():
+		return nil, fmt.Errorf("wait in queue: %w", ctx.Err())
+	}
+	defer func() { <-q.sem }()
+
+	call := &scrapeCall{module: module, done: make(chan struct{})}
+	c.mu.Lock()
+	q.call = call
+	c.mu.Unlock()
+
+	call.metrics, call.err = c.scrapeTarget(ctx, target, module, client)
+
+	c.mu.Lock()
+	q.call = nil
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.metrics, call.err
+}
+
+// acquireQueue returns the scrape queue of the router. Targets with
+// different addresses of the same router share the queue.
+func (c *Collector) acquireQueue(target string) *queue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.queues == nil {
+		c.queues = map[string]*queue{}
+	}
+	key := queueKey(target)
+	q, ok := c.queues[key]
+	if !ok {
+		q = newQueue()
+		c.queues[key] = q
+	}
+	q.users++
+	return q
+}
+
+// releaseQueue removes the queue of the router if it's not used anymore.
+func (c *Collector) releaseQueue(target string, q *queue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	q.users--
+	if q.users == 0 {
+		delete(c.queues, queueKey(target))
+	}
+}
+
+// queueKey returns the normalized host of the target address, that may
+// have scheme and port.
+func queueKey(target string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(target, "http://"), "https://")
+	host, _, _ = strings.Cut(host, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap().String()
+	}
+	return strings.ToLower(host)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollector_scrape(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	t.Run("queue timeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		metrics := NewMockConnectBox(ctrl)
+		metrics.EXPECT().Login(gomock.Any()).DoAndReturn(func(context.Context) error {
+			close(started)
+			<-release
+			return errors.New("fail")
+		})
+
+		col := &Collector{
+			queueTimeout: 10 * time.Millisecond,
+			targets: map[string]ConnectBox{
+				"127.0.0.1": metrics,
+			},
+		}
+
+		done := make(chan error)
+		go func() {
+			_, err := col.scrape(context.Background(), "127.0.0.1", "", metrics)
+			done <- err
+		}()
+		<-started
+
+		req, err := http.NewRequest(http.MethodGet, "/probe?target=127.0.0.1", nil)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		col.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+		close(release)
+		require.ErrorContains(t, <-done, "login")
+	})
+
+	t.Run("coalesce", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		release := make(chan struct{})
+
+		// Only one scrape is expected
+		metrics := NewMockConnectBox(ctrl)
+		metrics.EXPECT().Login(gomock.Any()).DoAndReturn(func(context.Context) error {
+			<-release
+			return nil
+		})
+		metrics.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(nil).Times(defaultCollectors())
+		metrics.EXPECT().Logout(gomock.Any()).Return(nil)
+
+		col := &Collector{
+			timeout:  time.Second,
+			coalesce: true,
+			targets: map[string]ConnectBox{
+				"127.0.0.1": metrics,
+			},
+		}
+
+		first := make(chan error)
+		go func() {
+			_, err := col.scrape(context.Background(), "127.0.0.1", "", metrics)
+			first <- err
+		}()
+
+		// Wait for the first scrape to start
+		q := col.acquireQueue("127.0.0.1")
+		defer col.releaseQueue("127.0.0.1", q)
+		var call *scrapeCall
+		for call == nil {
+			col.mu.Lock()
+			call = q.call
+			col.mu.Unlock()
+			runtime.Gosched()
+		}
+
+		// The second request joins the first one instead of waiting
+		// in the queue, so it fails on cancelled context right away
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := col.scrape(ctx, "127.0.0.1", "", metrics)
+		require.ErrorContains(t, err, "wait for scrape")
+
+		close(release)
+		require.NoError(t, <-first)
+	})
+
+	t.Run("coalesce shares result", func(t *testing.T) {
+		// No scrape is expected
+		metrics := NewMockConnectBox(gomock.NewController(t))
+
+		col := &Collector{
+			coalesce: true,
+			targets: map[string]ConnectBox{
+				"127.0.0.1": metrics,
+			},
+		}
+		name := "connect_box_cm_system_uptime"
+		families := []*dto.MetricFamily{{Name: &name}}
+		call := &scrapeCall{
+			done:    make(chan struct{}),
+			metrics: families,
+		}
+		close(call.done)
+		q := col.acquireQueue("127.0.0.1")
+		defer col.releaseQueue("127.0.0.1", q)
+		q.call = call
+
+		m, err := col.scrape(context.Background(), "127.0.0.1", "", metrics)
+		require.NoError(t, err)
+		require.Equal(t, families, m)
+	})
+}
+
+func TestCollector_acquireQueue(t *testing.T) {
+	col := &Collector{}
+
+	// Addresses of the same router share the queue
+	q := col.acquireQueue("192.168.178.1")
+	require.Same(t, q, col.acquireQueue("http://192.168.178.1:80/"))
+	require.Same(t, q, col.acquireQueue("::ffff:192.168.178.1"))
+	require.NotSame(t, q, col.acquireQueue("192.168.178.2"))
+
+	// The queue is removed when it's not used
+	col.releaseQueue("192.168.178.1", q)
+	col.releaseQueue("192.168.178.1", q)
+	require.Len(t, col.queues, 2)
+	col.releaseQueue("192.168.178.1", q)
+	col.releaseQueue("192.168.178.2", col.queues["192.168.178.2"])
+	require.Empty(t, col.queues)
+}
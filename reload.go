@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	reloadSuccessGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "connect_box_exporter_config_last_reload_successful",
+		Help: "Whether the last config reload was successful.",
+	})
+	reloadTimestampGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "connect_box_exporter_config_last_reload_success_timestamp_seconds",
+		Help: "Time of the last successful config reload.",
+	})
+)
+
+// Reload replaces targets, modules, auth profiles and retry policies with
+// the ones from the new config. Clients of targets with unchanged
+// credentials and retry policy are kept, so their sessions and login
+// backoff survive the reload. Probes in progress finish with the replaced
+// clients, and their sessions are closed afterwards. Other settings can
+// only be changed with a restart. Polling must be stopped before reload.
+func (c *Collector) Reload(ctx context.Context, conf Config, targets map[string]ConnectBox) {
+	old := c.load(conf, targets)
+	c.closeSessions(ctx, old)
+}
+
+// load builds target settings from the config, and swaps them into the
+// collector. It returns the replaced clients.
+func (c *Collector) load(conf Config, targets map[string]ConnectBox) map[ConnectBox]string {
+	var allowedCIDRs []netip.Prefix
+	for _, s := range conf.AllowedCIDRs {
+		// Prefixes are validated when reading the config
+		allowedCIDRs = append(allowedCIDRs, netip.MustParsePrefix(s))
+	}
+
+	modules := make(map[string]map[string]bool, len(conf.Modules))
+	for name, m := range conf.Modules {
+		modules[name] = make(map[string]bool, len(m.Collectors))
+		for _, col := range m.Collectors {
+			modules[name][col] = true
+		}
+	}
+	targetConfs := make(map[string]Target, len(conf.Targets))
+	for _, t := range conf.Targets {
+		targetConfs[t.Addr] = t
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	old := c.clients()
+	clients := make(map[string]ConnectBox, len(targets))
+	for addr, client := range targets {
+		prev, ok := c.targets[addr]
+		if ok && sameTarget(c.targetConfs[addr], c.retry, targetConfs[addr], conf.Retry) {
+			clients[addr] = prev
+		} else {
+			clients[addr] = c.wrap(addr, client, retryPolicy(targetConfs[addr], conf.Retry))
+		}
+		delete(old, clients[addr])
+	}
+	dynamic := make(map[dynamicTarget]*dynamicClient, len(c.dynamic))
+	for key, d := range c.dynamic {
+		addr := netip.MustParseAddr(key.addr)
+		_, static := targetConfs[key.addr]
+		keep := !static && reflect.DeepEqual(c.retry, conf.Retry) &&
+			c.authProfiles[key.auth] == conf.AuthProfiles[key.auth] &&
+			slices.ContainsFunc(allowedCIDRs, func(p netip.Prefix) bool { return p.Contains(addr) })
+		if keep {
+			dynamic[key] = d
+			delete(old, d.client)
+		}
+	}
+	prevDynamic := c.dynamic
+	prevStatic := c.targetConfs
+
+	c.targets = clients
+	c.targetConfs = targetConfs
+	c.modules = modules
+	c.retry = conf.Retry
+	c.authProfiles = conf.AuthProfiles
+	c.allowedCIDRs = allowedCIDRs
+	c.dynamic = dynamic
+	// Targets still in use are not forgotten
+	for key := range prevDynamic {
+		c.forget(key.addr)
+	}
+	for addr := range prevStatic {
+		c.forget(addr)
+	}
+	// Removed targets and targets without polling are scraped
+	// on request again
+	for target := range c.pollers {
+		if targetConfs[target].PollInterval == 0 {
+			delete(c.pollers, target)
+		}
+	}
+	return old
+}
+
+// sameTarget checks if the target client can be kept after reload.
+func sameTarget(prev Target, prevRetry RetryPolicy, next Target, nextRetry RetryPolicy) bool {
+	return prev.Username == next.Username &&
+		prev.Password == next.Password &&
+		reflect.DeepEqual(retryPolicy(prev, prevRetry), retryPolicy(next, nextRetry))
+}
+
+// retryPolicy returns the retry policy of the target.
+func retryPolicy(t Target, global RetryPolicy) RetryPolicy {
+	if t.Retry != nil {
+		return *t.Retry
+	}
+	return global
+}
+
+// clients returns all clients of the collector with their addresses.
+// It must be called with the mutex locked.
+func (c *Collector) clients() map[ConnectBox]string {
+	clients := make(map[ConnectBox]string, len(c.targets)+len(c.dynamic))
+	for addr, client := range c.targets {
+		clients[client] = addr
+	}
+	for key, d := range c.dynamic {
+		clients[d.client] = key.addr
+	}
+	return clients
+}
+
+// closeSessions logs out from the clients with active sessions. It waits
+// for scrapes of the target in progress to finish first.
+func (c *Collector) closeSessions(ctx context.Context, clients map[ConnectBox]string) {
+	for client, addr := range clients {
+		s, ok := client.(*session)
+		if !ok {
+			continue
+		}
+
+		q := c.acquireQueue(addr)
+		select {
+		case q.sem <- struct{}{}:
+			if err := s.Close(ctx); err != nil {
+				log.Printf("Failed to logout from %s: %v", addr, err)
+			}
+			<-q.sem
+		case <-ctx.Done():
+			log.Printf("Failed to logout from %s: %v", addr, ctx.Err())
+		}
+		c.releaseQueue(addr, q)
+	}
+}
+
+// reloader applies the config file to the collector on demand, and
+// restarts polling of targets.
+type reloader struct {
+	ctx       context.Context
+	file      string
+	collector *Collector
+
+	mu          sync.Mutex
+	stopPolling context.CancelFunc
+}
+
+func newReloader(ctx context.Context, file string, collector *Collector) *reloader {
+	return &reloader{ctx: ctx, file: file, collector: collector}
+}
+
+// Start starts polling targets from the initial config.
+func (r *reloader) Start(conf Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.start(conf)
+}
+
+// Reload reads the config file, creates clients for the targets, and swaps
+// them into the collector. The current config stays in use on failure.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := ReadConfig(r.file)
+	if err != nil {
+		reloadSuccessGauge.Set(0)
+		return fmt.Errorf("read config: %w", err)
+	}
+	targets, err := newTargets(conf)
+	if err != nil {
+		reloadSuccessGauge.Set(0)
+		return fmt.Errorf("create targets: %w", err)
+	}
+
+	if r.stopPolling != nil {
+		r.stopPolling()
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, conf.Timeout)
+	defer cancel()
+	r.collector.Reload(ctx, conf, targets)
+	r.start(conf)
+	log.Printf("Config reloaded")
+	return nil
+}
+
+// start starts polling targets, and records the successful reload.
+func (r *reloader) start(conf Config) {
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.stopPolling = cancel
+	for _, t := range conf.Targets {
+		if t.PollInterval > 0 {
+			r.collector.Poll(ctx, t.Addr, t.PollInterval, t.StaleAfter)
+		}
+	}
+	reloadSuccessGauge.Set(1)
+	reloadTimestampGauge.SetToCurrentTime()
+}
+
+// ServeHTTP handles reload requests.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Method not allowed")) //nolint:errcheck,gosec
+		return
+	}
+	if err := r.Reload(); err != nil {
+		log.Printf("Failed to reload config: %v", err)
+		http500(w, fmt.Sprintf("Failed to reload config: %v", err))
+	}
+}
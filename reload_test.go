@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCollector_Reload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	oldClient := NewMockConnectBox(ctrl)
+	oldClient.EXPECT().Login(gomock.Any()).Return(nil)
+	oldClient.EXPECT().Logout(gomock.Any()).Return(nil)
+	nextClient := NewMockConnectBox(ctrl)
+
+	col := NewCollector(Config{
+		Timeout:       time.Second,
+		SessionMaxAge: time.Minute,
+		Targets:       []Target{{Addr: "127.0.0.1"}},
+	}, map[string]ConnectBox{"127.0.0.1": oldClient})
+
+	// Start the session of the old client
+	s, ok := col.targets["127.0.0.1"].(*session)
+	require.True(t, ok)
+	require.NoError(t, s.Login(context.Background()))
+	col.events = map[string]*eventLog{"127.0.0.1": {}}
+
+	col.Reload(context.Background(), Config{
+		Timeout: time.Second,
+		Targets: []Target{{Addr: "127.0.0.2", Module: "rf_only"}},
+		Modules: map[string]Module{"rf_only": {Collectors: []string{"downstream_table"}}},
+	}, map[string]ConnectBox{"127.0.0.2": nextClient})
+
+	_, _, err := col.client("127.0.0.1", "")
+	require.ErrorIs(t, err, errUnknownTarget)
+	_, _, err = col.client("127.0.0.2", "")
+	require.NoError(t, err)
+	require.Equal(t, "rf_only", col.targetConf("127.0.0.2").Module)
+	require.NotContains(t, col.events, "127.0.0.1")
+	_, ok = col.module("rf_only")
+	require.True(t, ok)
+}
+
+func TestCollector_Reload_keepClients(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// Only the client with changed credentials is logged out
+	changed := NewMockConnectBox(ctrl)
+	changed.EXPECT().Login(gomock.Any()).Return(nil)
+	changed.EXPECT().Logout(gomock.Any()).Return(nil)
+	kept := NewMockConnectBox(ctrl)
+	kept.EXPECT().Login(gomock.Any()).Return(nil)
+
+	conf := Config{
+		Timeout:       time.Second,
+		SessionMaxAge: time.Minute,
+		LoginBackoff:  durationPtr(time.Minute),
+		Targets: []Target{
+			{Addr: "127.0.0.1", Password: "password"},
+			{Addr: "127.0.0.2", Password: "password"},
+		},
+	}
+	col := NewCollector(conf, map[string]ConnectBox{
+		"127.0.0.1": kept,
+		"127.0.0.2": changed,
+	})
+	prevKept := col.targets["127.0.0.1"]
+	prevChanged := col.targets["127.0.0.2"]
+	require.NoError(t, prevKept.Login(context.Background()))
+	require.NoError(t, prevChanged.Login(context.Background()))
+
+	conf.Targets = []Target{
+		{Addr: "127.0.0.1", Password: "password"},
+		{Addr: "127.0.0.2", Password: "new-password"},
+	}
+	col.Reload(context.Background(), conf, map[string]ConnectBox{
+		"127.0.0.1": NewMockConnectBox(ctrl),
+		"127.0.0.2": NewMockConnectBox(ctrl),
+	})
+
+	require.Same(t, prevKept, col.targets["127.0.0.1"])
+	require.NotSame(t, prevChanged, col.targets["127.0.0.2"])
+}
+
+func TestCollector_Reload_stopPolling(t *testing.T) {
+	conf := Config{
+		Timeout: time.Second,
+		Targets: []Target{{Addr: "127.0.0.1", PollInterval: time.Minute}},
+	}
+	col := NewCollector(conf, map[string]ConnectBox{
+		"127.0.0.1": NewMockConnectBox(gomock.NewController(t)),
+	})
+	col.pollers = map[string]*poller{"127.0.0.1": {staleAfter: time.Minute}}
+
+	// The target stays, but is not polled anymore
+	conf.Targets = []Target{{Addr: "127.0.0.1"}}
+	col.Reload(context.Background(), conf, map[string]ConnectBox{
+		"127.0.0.1": NewMockConnectBox(gomock.NewController(t)),
+	})
+
+	require.Nil(t, col.poller("127.0.0.1"))
+}
+
+func TestReloader_ServeHTTP(t *testing.T) {
+	file, err := os.CreateTemp(os.TempDir(), "connectbox-exporter.yml")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	col := NewCollector(Config{Timeout: time.Second}, nil)
+	r := newReloader(context.Background(), file.Name(), col)
+
+	t.Run("wrong method", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/-/reload", nil)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		err := os.WriteFile(file.Name(), []byte(
+			"targets:\n"+
+				"  - addr: 192.168.178.1\n"+
+				"    password: password",
+		), 0o600)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, "/-/reload", nil)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, 1.0, testutil.ToFloat64(reloadSuccessGauge))
+		require.InDelta(t, float64(time.Now().Unix()),
+			testutil.ToFloat64(reloadTimestampGauge), 5)
+		_, _, err = col.client("192.168.178.1", "")
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		err := os.WriteFile(file.Name(), []byte("hello: world: !"), 0o600)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(http.MethodPost, "/-/reload", nil)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Equal(t, 0.0, testutil.ToFloat64(reloadSuccessGauge))
+
+		// The current config stays in use
+		_, _, err = col.client("192.168.178.1", "")
+		require.NoError(t, err)
+	})
+}
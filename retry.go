@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Kinds of errors, that can be retried.
+const (
+	RetryOnRequest = "request"
+	RetryOnDecode  = "decode"
+)
+
+var retriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "connect_box_exporter_retries_total",
+	Help: "Retried requests to the router API.",
+}, []string{"target", "fn"})
+
+// retryClient is a ConnectBox client, that retries failed requests
+// according to the retry policy.
+type retryClient struct {
+	target string
+	client ConnectBox
+	policy RetryPolicy
+}
+
+func newRetryClient(target string, client ConnectBox, policy RetryPolicy) *retryClient {
+	return &retryClient{target: target, client: client, policy: policy}
+}
+
+// Login logs in to the router.
+func (c *retryClient) Login(ctx context.Context) error {
+	return c.client.Login(ctx) //nolint:wrapcheck
+}
+
+// Logout logs out from the router.
+func (c *retryClient) Logout(ctx context.Context) error {
+	return c.client.Logout(ctx) //nolint:wrapcheck
+}
+
+// Get gets data from the router. Failed requests are retried with
+// exponential backoff, if the error is retryable. The output is reset
+// before each retry, because a failed decoding might leave it partially
+// filled, and decoding appends to slices.
+func (c *retryClient) Get(ctx context.Context, fn string, out any) error {
+	delay := c.policy.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			reset(out)
+		}
+		err = c.client.Get(ctx, fn, out)
+		if err == nil || attempt >= c.policy.Attempts || !c.retryable(ctx, err) {
+			return err //nolint:wrapcheck
+		}
+
+		select {
+		case <-ctx.Done():
+			return err //nolint:wrapcheck
+		case <-time.After(delay):
+		}
+		delay *= 2
+		retriesCounter.WithLabelValues(c.target, fn).Inc()
+	}
+}
+
+// reset sets the value, that out points to, to zero.
+func reset(out any) {
+	v := reflect.ValueOf(out)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v.Elem().SetZero()
+	}
+}
+
+// retryable checks if the error can be retried by the policy.
+func (c *retryClient) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	kind := RetryOnRequest
+	if isDecodeError(err) {
+		kind = RetryOnDecode
+	}
+	for _, k := range c.policy.RetryOn {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
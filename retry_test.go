@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRetryClient_Get(t *testing.T) {
+	retriesCounter.Reset()
+
+	policy := RetryPolicy{
+		Attempts: 3,
+		Backoff:  time.Millisecond,
+		RetryOn:  []string{RetryOnRequest},
+	}
+
+	t.Run("success after retry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		gomock.InOrder(
+			client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+				Return(errors.New("fail")),
+			client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+				Return(nil),
+		)
+
+		c := newRetryClient("success", client, policy)
+		require.NoError(t, c.Get(context.Background(), FnCMState, &CMState{}))
+		require.Equal(t, 1.0, testutil.ToFloat64(
+			retriesCounter.WithLabelValues("success", FnCMState)))
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+			Return(errors.New("fail")).Times(3)
+
+		c := newRetryClient("exhausted", client, policy)
+		require.EqualError(t, c.Get(context.Background(), FnCMState, &CMState{}), "fail")
+		require.Equal(t, 2.0, testutil.ToFloat64(
+			retriesCounter.WithLabelValues("exhausted", FnCMState)))
+	})
+
+	t.Run("reset output before retry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		decodeRetry := policy
+		decodeRetry.RetryOn = []string{RetryOnDecode}
+
+		client := NewMockConnectBox(ctrl)
+		gomock.InOrder(
+			client.EXPECT().Get(gomock.Any(), FnDownstreamTable, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, out any) error {
+					// Partially decoded response
+					table := out.(*DownstreamTable)
+					table.Channels = append(table.Channels, DownstreamTableChannel{ChannelID: "1"})
+					return fmt.Errorf("unmarshal response: %w", errors.New("fail"))
+				}),
+			client.EXPECT().Get(gomock.Any(), FnDownstreamTable, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, out any) error {
+					table := out.(*DownstreamTable)
+					table.Channels = append(table.Channels,
+						DownstreamTableChannel{ChannelID: "1"},
+						DownstreamTableChannel{ChannelID: "2"},
+					)
+					return nil
+				}),
+		)
+
+		c := newRetryClient("reset", client, decodeRetry)
+		var table DownstreamTable
+		require.NoError(t, c.Get(context.Background(), FnDownstreamTable, &table))
+		require.Len(t, table.Channels, 2)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+			Return(fmt.Errorf("unmarshal response: %w", errors.New("fail")))
+
+		c := newRetryClient("decode", client, policy)
+		require.Error(t, c.Get(context.Background(), FnCMState, &CMState{}))
+		require.Equal(t, 0.0, testutil.ToFloat64(
+			retriesCounter.WithLabelValues("decode", FnCMState)))
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		client := NewMockConnectBox(ctrl)
+		client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+			DoAndReturn(func(context.Context, string, any) error {
+				cancel()
+				return errors.New("fail")
+			})
+
+		c := newRetryClient("cancelled", client, policy)
+		require.EqualError(t, c.Get(ctx, FnCMState, &CMState{}), "fail")
+	})
+}
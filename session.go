@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// session is a ConnectBox client, that stays logged in between scrapes.
+// The session is renewed when it gets older than maxAge, or when
+// a request fails, because the router might have dropped it.
+type session struct {
+	target string
+	client ConnectBox
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	started time.Time
+}
+
+func newSession(target string, client ConnectBox, maxAge time.Duration) *session {
+	return &session{target: target, client: client, maxAge: maxAge}
+}
+
+// Login logs in to the router, unless there is an active session.
+func (s *session) Login(ctx context.Context) error {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+
+	if !started.IsZero() && time.Since(started) < s.maxAge {
+		return nil
+	}
+	if !started.IsZero() {
+		if err := s.Close(ctx); err != nil {
+			log.Printf("Failed to close expired session of %s: %v", s.target, err)
+		}
+	}
+	return s.login(ctx)
+}
+
+// Logout keeps the session open for the next scrape.
+func (s *session) Logout(context.Context) error {
+	return nil
+}
+
+// Get gets data from the router. If the request fails, it logs in again
+// and retries the request once. Responses that can't be decoded don't
+// mean the session is dropped, e.g. the function might be not supported
+// by the firmware, so they are returned as is.
+func (s *session) Get(ctx context.Context, fn string, out any) error {
+	err := s.client.Get(ctx, fn, out)
+	if err == nil || ctx.Err() != nil || isDecodeError(err) {
+		return err //nolint:wrapcheck
+	}
+
+	log.Printf("Request to %s failed, renewing session: %v", s.target, err)
+	if lerr := s.login(ctx); lerr != nil {
+		log.Printf("Failed to renew session of %s: %v", s.target, lerr)
+		return err //nolint:wrapcheck
+	}
+	return s.client.Get(ctx, fn, out) //nolint:wrapcheck
+}
+
+// Close logs out from the router.
+func (s *session) Close(ctx context.Context) error {
+	s.mu.Lock()
+	active := !s.started.IsZero()
+	s.started = time.Time{}
+	s.mu.Unlock()
+
+	if !active {
+		return nil
+	}
+	return s.client.Logout(ctx) //nolint:wrapcheck
+}
+
+func (s *session) login(ctx context.Context) error {
+	s.mu.Lock()
+	s.started = time.Time{}
+	s.mu.Unlock()
+
+	if err := s.client.Login(ctx); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	s.mu.Lock()
+	s.started = time.Now()
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSession(t *testing.T) {
+	log.SetOutput(io.Discard)
+
+	t.Run("reuse session", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		client.EXPECT().Login(gomock.Any()).Return(nil)
+		client.EXPECT().Logout(gomock.Any()).Return(nil)
+
+		s := newSession("127.0.0.1", client, time.Hour)
+		ctx := context.Background()
+		require.NoError(t, s.Login(ctx))
+		require.NoError(t, s.Logout(ctx))
+		require.NoError(t, s.Login(ctx))
+		require.NoError(t, s.Logout(ctx))
+		require.NoError(t, s.Close(ctx))
+		require.NoError(t, s.Close(ctx))
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		gomock.InOrder(
+			client.EXPECT().Login(gomock.Any()).Return(nil),
+			client.EXPECT().Logout(gomock.Any()).Return(nil),
+			client.EXPECT().Login(gomock.Any()).Return(nil),
+		)
+
+		s := newSession("127.0.0.1", client, time.Hour)
+		ctx := context.Background()
+		require.NoError(t, s.Login(ctx))
+		s.started = time.Now().Add(-2 * time.Hour)
+		require.NoError(t, s.Login(ctx))
+	})
+
+	t.Run("failed login", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		client.EXPECT().Login(gomock.Any()).Return(errors.New("fail"))
+		client.EXPECT().Login(gomock.Any()).Return(nil)
+
+		s := newSession("127.0.0.1", client, time.Hour)
+		ctx := context.Background()
+		require.Error(t, s.Login(ctx))
+		require.NoError(t, s.Login(ctx))
+	})
+
+	t.Run("renew session on failed request", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		gomock.InOrder(
+			client.EXPECT().Login(gomock.Any()).Return(nil),
+			client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+				Return(errors.New("fail")),
+			client.EXPECT().Login(gomock.Any()).Return(nil),
+			client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+				Return(nil),
+		)
+
+		s := newSession("127.0.0.1", client, time.Hour)
+		ctx := context.Background()
+		require.NoError(t, s.Login(ctx))
+		require.NoError(t, s.Get(ctx, FnCMState, &CMState{}))
+	})
+
+	t.Run("keep session on decode error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		gomock.InOrder(
+			client.EXPECT().Login(gomock.Any()).Return(nil),
+			client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+				Return(fmt.Errorf("unmarshal response: %w", errors.New("fail"))),
+		)
+
+		s := newSession("127.0.0.1", client, time.Hour)
+		ctx := context.Background()
+		require.NoError(t, s.Login(ctx))
+		require.ErrorContains(t, s.Get(ctx, FnCMState, &CMState{}), "unmarshal response")
+		require.False(t, s.started.IsZero())
+	})
+
+	t.Run("failed to renew session", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		client := NewMockConnectBox(ctrl)
+		gomock.InOrder(
+			client.EXPECT().Login(gomock.Any()).Return(nil),
+			client.EXPECT().Get(gomock.Any(), FnCMState, gomock.Any()).
+				Return(errors.New("request failed")),
+			client.EXPECT().Login(gomock.Any()).Return(errors.New("login failed")),
+		)
+
+		s := newSession("127.0.0.1", client, time.Hour)
+		ctx := context.Background()
+		require.NoError(t, s.Login(ctx))
+		require.EqualError(t, s.Get(ctx, FnCMState, &CMState{}), "request failed")
+		require.True(t, s.started.IsZero())
+	})
+}